@@ -1,33 +1,76 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"os"
+	"time"
 
 	"user-service/internal/db"
+	"user-service/internal/logging"
+	"user-service/internal/middleware"
 	"user-service/internal/router"
+	"user-service/internal/services"
+	"user-service/internal/tracing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load .env
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	// Load .env, then log as configured by it
+	envErr := godotenv.Load()
+	if err := logging.Setup(); err != nil {
+		fatal("Logging setup failed", err)
+	}
+	if envErr != nil {
+		slog.Info("No .env file found, using system environment variables")
 	}
 
+	// Export traces as configured by the OTEL_* variables
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("Tracing setup failed", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Tracing shutdown failed", "error", err)
+		}
+	}()
+
 	// Connect DB
 	database, err := db.Connect()
 	if err != nil {
-		log.Fatal("DB connection failed:", err)
+		fatal("DB connection failed", err)
 	}
 	defer database.Close()
 
+	// Start the asynchronous job workers
+	jobRunner := services.NewJobRunner(database)
+	jobRunner.Start()
+	defer jobRunner.Stop()
+
+	webhookDispatcher := services.NewWebhookDispatcher(database)
+	webhookDispatcher.Start()
+	defer webhookDispatcher.Stop()
+
+	// Record usage rollups for every translation
+	usageMeter := services.NewUsageMeter(database)
+	usageMeter.Start()
+	defer usageMeter.Stop()
+
+	// Validate requests against the languages the pipelines actually serve
+	languageDiscovery := services.NewLanguageDiscovery()
+	languageDiscovery.Start()
+	defer languageDiscovery.Stop()
+
 	// Fiber app
 	app := fiber.New(fiber.Config{
+		// Stream large request bodies (CSV uploads) instead of rejecting them
+		StreamRequestBody: true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -41,11 +84,15 @@ func main() {
 	})
 
 	// Middleware
-	app.Use(logger.New())
+	app.Use(middleware.RequestID())
+	app.Use(middleware.Tracing())
+	app.Use(middleware.Metrics())
+	app.Use(middleware.AccessLog())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization,X-Access-Type",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-API-Key,X-Access-Type,X-Request-ID,Traceparent,Tracestate",
+		ExposeHeaders: "Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset,X-Request-ID,X-Translation-Stale",
 	}))
 
 	// Routes
@@ -57,9 +104,14 @@ func main() {
 		port = "3001"
 	}
 
-	log.Printf("Translation Service running on port %s", port)
+	slog.Info("Translation Service running", "port", port)
 	if err := app.Listen(":" + port); err != nil {
-		log.Fatal(err)
+		fatal("Server failed", err)
 	}
 }
 
+// fatal logs an error that keeps the service from running and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-service/internal/services"
+)
+
+// backend translates through a running server or directly against Bhashini
+type backend interface {
+	translateTexts(texts []string, sourceLang, targetLang string) ([]string, error)
+	translateMarkdown(document, sourceLang, targetLang string) (string, error)
+	translateCSV(data []byte, opts services.CSVOptions) ([]byte, error)
+	name() string
+}
+
+// directBackend calls Bhashini in-process with the local credentials from
+// BHASHINI_USER_ID and BHASHINI_API_KEY. There is no cache.
+type directBackend struct{}
+
+// service returns a fresh translation service; services are not safe for
+// concurrent use, so every call gets its own
+func (directBackend) service() *services.TranslationService {
+	return services.NewTranslationService(services.NewBhashiniClient(), nil)
+}
+
+func (b directBackend) translateTexts(texts []string, sourceLang, targetLang string) ([]string, error) {
+	return b.service().TranslateTexts(context.Background(), texts, sourceLang, targetLang)
+}
+
+func (b directBackend) translateMarkdown(document, sourceLang, targetLang string) (string, error) {
+	return services.NewMarkdownTranslator(b.service()).Translate(context.Background(), document, sourceLang, targetLang, services.DefaultFrontMatterKeys)
+}
+
+func (b directBackend) translateCSV(data []byte, opts services.CSVOptions) ([]byte, error) {
+	var output bytes.Buffer
+	if err := services.NewCSVTranslator(b.service()).Translate(context.Background(), bytes.NewReader(data), &output, opts); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+func (directBackend) name() string {
+	return "Bhashini directly"
+}
+
+// serverBackend calls the /v1 endpoints of a running translation service
+type serverBackend struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func newServerBackend(baseURL, apiKey string) *serverBackend {
+	return &serverBackend{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// batchItem mirrors handlers.TranslateBatchItem
+type batchItem struct {
+	SourceText string `json:"source_text"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+}
+
+func (b *serverBackend) translateTexts(texts []string, sourceLang, targetLang string) ([]string, error) {
+	items := make([]batchItem, len(texts))
+	for i, text := range texts {
+		items[i] = batchItem{SourceText: text, SourceLang: sourceLang, TargetLang: targetLang}
+	}
+
+	var data struct {
+		TranslatedTexts []string `json:"translated_texts"`
+	}
+	if err := b.postJSON("/v1/translate/batch", map[string]interface{}{"items": items}, &data); err != nil {
+		return nil, err
+	}
+	if len(data.TranslatedTexts) != len(texts) {
+		return nil, fmt.Errorf("expected %d translations, received %d", len(texts), len(data.TranslatedTexts))
+	}
+	return data.TranslatedTexts, nil
+}
+
+func (b *serverBackend) translateMarkdown(document, sourceLang, targetLang string) (string, error) {
+	request := map[string]string{"markdown": document, "source_lang": sourceLang, "target_lang": targetLang}
+	var data struct {
+		Markdown string `json:"markdown"`
+	}
+	if err := b.postJSON("/v1/translate/markdown", request, &data); err != nil {
+		return "", err
+	}
+	return data.Markdown, nil
+}
+
+func (b *serverBackend) translateCSV(data []byte, opts services.CSVOptions) ([]byte, error) {
+	query := url.Values{}
+	query.Set("source_lang", opts.SourceLang)
+	query.Set("target_langs", strings.Join(opts.TargetLangs, ","))
+	query.Set("columns", strings.Join(opts.Columns, ","))
+	query.Set("delimiter", string(opts.Delimiter))
+
+	httpReq, err := http.NewRequest("POST", b.baseURL+"/v1/translate/csv?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "text/csv")
+	return b.do(httpReq)
+}
+
+func (b *serverBackend) name() string {
+	return b.baseURL
+}
+
+// postJSON posts a JSON request and decodes the "data" field of the response
+func (b *serverBackend) postJSON(path string, request, data interface{}) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", b.baseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	body, err := b.do(httpReq)
+	if err != nil {
+		return err
+	}
+
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: data}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// do sends a request and returns the body of a 2xx response, turning the
+// service's {"status": "error", "error": ...} responses into errors
+func (b *serverBackend) do(httpReq *http.Request) ([]byte, error) {
+	if b.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errorResponse struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error != "" {
+			return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, errorResponse.Error)
+		}
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// stringsPerRequest is how many strings of a locale file go in one request
+const stringsPerRequest = 50
+
+// placeholderPattern matches interpolation placeholders that must survive
+// translation: {{name}}, {name}, printf verbs such as %s or %1$d, and tags
+var placeholderPattern = regexp.MustCompile(`\{\{[^{}]*\}\}|\{[A-Za-z0-9_.]+\}|%(?:\d+\$)?[-+ #0]*\d*(?:\.\d+)?[sdfiuxXvq]|</?[A-Za-z][^<>]*>`)
+
+// tokenPattern matches the numbered tokens placeholders are swapped for
+var tokenPattern = regexp.MustCompile(`\{\{\s*(\d+)\s*\}\}`)
+
+// protectPlaceholders swaps placeholders for numbered {{N}} tokens, which the
+// translation models leave alone, and returns the placeholders in order
+func protectPlaceholders(text string) (string, []string) {
+	var placeholders []string
+	protected := placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		placeholders = append(placeholders, placeholder)
+		return "{{" + strconv.Itoa(len(placeholders)-1) + "}}"
+	})
+	return protected, placeholders
+}
+
+// restorePlaceholders puts placeholders back in place of their tokens. It
+// reports false unless every token came back exactly once.
+func restorePlaceholders(translated string, placeholders []string) (string, bool) {
+	seen := make([]bool, len(placeholders))
+	ok := true
+	restored := tokenPattern.ReplaceAllStringFunc(translated, func(token string) string {
+		n, err := strconv.Atoi(tokenPattern.FindStringSubmatch(token)[1])
+		if err != nil || n >= len(placeholders) || seen[n] {
+			ok = false
+			return token
+		}
+		seen[n] = true
+		return placeholders[n]
+	})
+	for _, s := range seen {
+		ok = ok && s
+	}
+	return restored, ok
+}
+
+// translateStrings translates the strings of a locale file with up to
+// concurrency requests at a time. Blank strings are kept as they are, and a
+// string whose placeholders do not survive translation keeps its source text
+// with a warning.
+func translateStrings(b backend, texts []string, sourceLang, targetLang string, concurrency int) ([]string, error) {
+	results := append([]string(nil), texts...)
+
+	var indexes []int
+	protected := make([]string, len(texts))
+	placeholders := make([][]string, len(texts))
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		protected[i], placeholders[i] = protectPlaceholders(text)
+		indexes = append(indexes, i)
+	}
+
+	chunks := make(chan []int)
+	go func() {
+		defer close(chunks)
+		for start := 0; start < len(indexes); start += stringsPerRequest {
+			end := start + stringsPerRequest
+			if end > len(indexes) {
+				end = len(indexes)
+			}
+			chunks <- indexes[start:end]
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for n := 0; n < concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				batch := make([]string, len(chunk))
+				for j, i := range chunk {
+					batch[j] = protected[i]
+				}
+
+				translated, err := b.translateTexts(batch, sourceLang, targetLang)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+
+				for j, i := range chunk {
+					restored, ok := restorePlaceholders(translated[j], placeholders[i])
+					if !ok {
+						fmt.Fprintf(os.Stderr, "warning: placeholders lost in %q (%s), keeping the source text\n", texts[i], targetLang)
+						continue
+					}
+					results[i] = restored
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// jsonNode is a JSON value that keeps the key order of objects, so a
+// translated locale file diffs cleanly against its source
+type jsonNode struct {
+	keys     []string    // object keys, in order
+	children []*jsonNode // object values or array elements
+	isObject bool
+	isArray  bool
+	isString bool
+	text     string // string value
+	raw      string // number, bool or null
+}
+
+// parseJSONLocale parses a JSON locale file such as {"nav": {"home": "Home"}}
+func parseJSONLocale(data []byte) (*jsonNode, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	root, err := parseJSONNode(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON locale file: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON locale file: unexpected data after the top-level value")
+	}
+	return root, nil
+}
+
+// parseJSONNode reads one value from the decoder
+func parseJSONNode(decoder *json.Decoder) (*jsonNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		node := &jsonNode{isObject: value == '{', isArray: value == '['}
+		for decoder.More() {
+			if node.isObject {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, keyToken.(string))
+			}
+			child, err := parseJSONNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+		if _, err := decoder.Token(); err != nil { // closing delimiter
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &jsonNode{isString: true, text: value}, nil
+	case json.Number:
+		return &jsonNode{raw: value.String()}, nil
+	case bool:
+		return &jsonNode{raw: strconv.FormatBool(value)}, nil
+	default:
+		return &jsonNode{raw: "null"}, nil
+	}
+}
+
+// stringNodes returns the string values of a tree in document order
+func (n *jsonNode) stringNodes() []*jsonNode {
+	if n.isString {
+		return []*jsonNode{n}
+	}
+	var nodes []*jsonNode
+	for _, child := range n.children {
+		nodes = append(nodes, child.stringNodes()...)
+	}
+	return nodes
+}
+
+// clone returns a deep copy of a tree
+func (n *jsonNode) clone() *jsonNode {
+	copied := *n
+	copied.children = make([]*jsonNode, len(n.children))
+	for i, child := range n.children {
+		copied.children[i] = child.clone()
+	}
+	return &copied
+}
+
+// encode writes a tree as JSON indented by two spaces
+func (n *jsonNode) encode(buf *bytes.Buffer, indent string) {
+	switch {
+	case n.isString:
+		buf.WriteString(quoteJSON(n.text))
+	case n.isObject || n.isArray:
+		opening, closing := "[", "]"
+		if n.isObject {
+			opening, closing = "{", "}"
+		}
+		if len(n.children) == 0 {
+			buf.WriteString(opening + closing)
+			return
+		}
+		buf.WriteString(opening + "\n")
+		for i, child := range n.children {
+			buf.WriteString(indent + "  ")
+			if n.isObject {
+				buf.WriteString(quoteJSON(n.keys[i]) + ": ")
+			}
+			child.encode(buf, indent+"  ")
+			if i < len(n.children)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + closing)
+	default:
+		buf.WriteString(n.raw)
+	}
+}
+
+// quoteJSON quotes a string without escaping HTML characters
+func quoteJSON(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// translateJSONLocale translates every string value of a locale file
+func translateJSONLocale(b backend, root *jsonNode, sourceLang, targetLang string, concurrency int) ([]byte, error) {
+	translated := root.clone()
+	nodes := translated.stringNodes()
+
+	texts := make([]string, len(nodes))
+	for i, node := range nodes {
+		texts[i] = node.text
+	}
+	results, err := translateStrings(b, texts, sourceLang, targetLang, concurrency)
+	if err != nil {
+		return nil, err
+	}
+	for i, node := range nodes {
+		node.text = results[i]
+	}
+
+	var buf bytes.Buffer
+	translated.encode(&buf, "")
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// poEntry is one blank-line separated block of a PO file
+type poEntry struct {
+	lines       []string
+	trailing    []string // blank lines after the block
+	msgid       string
+	msgidPlural string
+	hasPlural   bool
+	msgstrCount int  // number of msgstr / msgstr[n] keywords
+	msgstrStart int  // index of the first msgstr line, -1 if none
+	msgstrEnd   int  // index after the last msgstr line
+	translated  bool // whether any msgstr is already filled in
+	flagsLine   int  // index of the "#," line, -1 if none
+}
+
+// parsePO splits a PO file into entries, keeping every line so untouched
+// entries are written back byte for byte
+func parsePO(data []byte) []*poEntry {
+	var entries []*poEntry
+	var current *poEntry
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			if current == nil {
+				current = &poEntry{msgstrStart: -1, flagsLine: -1}
+				entries = append(entries, current)
+			}
+			current.trailing = append(current.trailing, line)
+			continue
+		}
+		if current == nil || len(current.trailing) > 0 {
+			current = &poEntry{msgstrStart: -1, flagsLine: -1}
+			entries = append(entries, current)
+		}
+		current.lines = append(current.lines, line)
+	}
+
+	for _, entry := range entries {
+		entry.parse()
+	}
+	return entries
+}
+
+// parse reads the keywords of an entry's lines
+func (e *poEntry) parse() {
+	keyword := ""
+	for i, line := range e.lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#,"):
+			e.flagsLine = i
+			keyword = ""
+		case strings.HasPrefix(trimmed, "#"):
+			keyword = ""
+		case strings.HasPrefix(trimmed, "msgctxt "):
+			keyword = "msgctxt"
+		case strings.HasPrefix(trimmed, "msgid_plural "):
+			keyword = "msgid_plural"
+			e.hasPlural = true
+			e.msgidPlural += unquotePO(strings.TrimPrefix(trimmed, "msgid_plural "))
+		case strings.HasPrefix(trimmed, "msgid "):
+			keyword = "msgid"
+			e.msgid += unquotePO(strings.TrimPrefix(trimmed, "msgid "))
+		case strings.HasPrefix(trimmed, "msgstr"):
+			keyword = "msgstr"
+			if e.msgstrStart < 0 {
+				e.msgstrStart = i
+			}
+			e.msgstrEnd = i + 1
+			e.msgstrCount++
+			if space := strings.Index(trimmed, " "); space > 0 && unquotePO(trimmed[space+1:]) != "" {
+				e.translated = true
+			}
+		case strings.HasPrefix(trimmed, `"`):
+			switch keyword {
+			case "msgid":
+				e.msgid += unquotePO(trimmed)
+			case "msgid_plural":
+				e.msgidPlural += unquotePO(trimmed)
+			case "msgstr":
+				e.msgstrEnd = i + 1
+				if unquotePO(trimmed) != "" {
+					e.translated = true
+				}
+			}
+		}
+	}
+}
+
+// needsTranslation reports whether an entry is a message without a translation
+func (e *poEntry) needsTranslation() bool {
+	return e.msgid != "" && e.msgstrStart >= 0 && !e.translated
+}
+
+// isHeader reports whether an entry is the header entry (msgid "")
+func (e *poEntry) isHeader() bool {
+	return e.msgid == "" && e.msgstrStart >= 0
+}
+
+// fill replaces an entry's empty msgstr lines with translations and marks the
+// entry fuzzy, so translators review machine translations before release
+func (e *poEntry) fill(singular, plural string) []string {
+	var msgstr []string
+	if e.hasPlural {
+		count := e.msgstrCount
+		if count < 2 {
+			count = 2
+		}
+		for n := 0; n < count; n++ {
+			text := plural
+			if n == 0 {
+				text = singular
+			}
+			msgstr = append(msgstr, quotePO(fmt.Sprintf("msgstr[%d]", n), text)...)
+		}
+	} else {
+		msgstr = quotePO("msgstr", singular)
+	}
+
+	lines := append([]string{}, e.lines[:e.msgstrStart]...)
+	lines = append(lines, msgstr...)
+	lines = append(lines, e.lines[e.msgstrEnd:]...)
+
+	if e.flagsLine >= 0 {
+		if !strings.Contains(lines[e.flagsLine], "fuzzy") {
+			lines[e.flagsLine] += ", fuzzy"
+		}
+		return lines
+	}
+
+	// Flags go after translator, extracted and reference comments
+	insertAt := 0
+	for insertAt < len(lines) {
+		trimmed := strings.TrimSpace(lines[insertAt])
+		if !strings.HasPrefix(trimmed, "# ") && trimmed != "#" && !strings.HasPrefix(trimmed, "#.") && !strings.HasPrefix(trimmed, "#:") {
+			break
+		}
+		insertAt++
+	}
+	lines = append(lines[:insertAt], append([]string{"#, fuzzy"}, lines[insertAt:]...)...)
+	return lines
+}
+
+// translatePO fills in the untranslated messages of a PO file and sets the
+// header's Language field
+func translatePO(b backend, entries []*poEntry, sourceLang, targetLang string, concurrency int) ([]byte, error) {
+	var texts []string
+	for _, entry := range entries {
+		if entry.needsTranslation() {
+			texts = append(texts, entry.msgid)
+			if entry.hasPlural {
+				texts = append(texts, entry.msgidPlural)
+			}
+		}
+	}
+
+	results, err := translateStrings(b, texts, sourceLang, targetLang, concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	next := 0
+	for _, entry := range entries {
+		lines := entry.lines
+		switch {
+		case entry.needsTranslation():
+			singular, plural := results[next], ""
+			next++
+			if entry.hasPlural {
+				plural = results[next]
+				next++
+			}
+			lines = entry.fill(singular, plural)
+		case entry.isHeader():
+			lines = make([]string, len(entry.lines))
+			for i, line := range entry.lines {
+				if strings.HasPrefix(strings.TrimSpace(line), `"Language:`) {
+					line = `"Language: ` + targetLang + `\n"`
+				}
+				lines[i] = line
+			}
+		}
+
+		for _, line := range lines {
+			buf.WriteString(line + "\n")
+		}
+		for _, line := range entry.trailing {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// unquotePO decodes one quoted PO string
+func unquotePO(quoted string) string {
+	quoted = strings.TrimSpace(quoted)
+	if unquoted, err := strconv.Unquote(quoted); err == nil {
+		return unquoted
+	}
+	return strings.Trim(quoted, `"`)
+}
+
+// quotePO writes a keyword and its value, splitting multi-line values after
+// each newline the way gettext tools do
+func quotePO(keyword, value string) []string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	if !strings.Contains(strings.TrimSuffix(value, "\n"), "\n") {
+		return []string{keyword + ` "` + escape.Replace(value) + `"`}
+	}
+
+	lines := []string{keyword + ` ""`}
+	for _, part := range strings.SplitAfter(value, "\n") {
+		if part != "" {
+			lines = append(lines, `"`+escape.Replace(part)+`"`)
+		}
+	}
+	return lines
+}
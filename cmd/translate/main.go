@@ -0,0 +1,388 @@
+// Command translate translates a string, standard input, or a file (JSON
+// locale, PO, CSV or Markdown) through a running translation service or
+// directly against Bhashini with local credentials.
+//
+// Usage:
+//
+//	translate -from en -to hi "Hello, how are you?"
+//	echo "Hello" | translate -from en -to hi,ta
+//	translate -from en -to hi,ta -f locales/en.json -o locales/{lang}.json
+//	translate -from en -to hi -f messages.po -o messages.hi.po
+//	translate -from en -to hi,ta -f products.csv -columns name,description -o products_translated.csv
+//	translate -server http://localhost:3001 -from en -to hi -f README.md -o README.hi.md
+//
+// With -server the service's API key is taken from -api-key or
+// TRANSLATE_API_KEY. Without -server (or TRANSLATE_SERVER) Bhashini is called
+// directly using BHASHINI_USER_ID and BHASHINI_API_KEY from the environment
+// or a .env file.
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"user-service/internal/constants"
+	"user-service/internal/services"
+
+	"github.com/joho/godotenv"
+)
+
+// Input formats
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatPO       = "po"
+	formatCSV      = "csv"
+	formatMarkdown = "markdown"
+)
+
+// options holds the parsed command-line flags
+type options struct {
+	sourceLang  string
+	targetLangs []string
+	inputPath   string
+	format      string
+	outputPath  string
+	server      string
+	apiKey      string
+	concurrency int
+	dryRun      bool
+	columns     []string
+	delimiter   rune
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "translate:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	// Local credentials for direct mode; a missing .env is fine
+	godotenv.Load()
+
+	var opts options
+	var targets, columns, delimiter string
+	flag.StringVar(&opts.sourceLang, "from", "", "source language code, e.g. en")
+	flag.StringVar(&targets, "to", "", "comma-separated target language codes, e.g. hi,ta")
+	flag.StringVar(&opts.inputPath, "f", "", "input file, or - for standard input")
+	flag.StringVar(&opts.format, "format", "", "input format: text, json, po, csv or markdown (default: from the file extension)")
+	flag.StringVar(&opts.outputPath, "o", "", "output file; {lang} is replaced by the target language (default: standard output)")
+	flag.StringVar(&opts.server, "server", os.Getenv("TRANSLATE_SERVER"), "base URL of a running translation service (default: call Bhashini directly)")
+	flag.StringVar(&opts.apiKey, "api-key", os.Getenv("TRANSLATE_API_KEY"), "API key for -server (default: TRANSLATE_API_KEY)")
+	flag.IntVar(&opts.concurrency, "concurrency", 4, "number of requests in flight at once")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "show what would be translated without calling any API")
+	flag.StringVar(&columns, "columns", "", "CSV: comma-separated columns to translate")
+	flag.StringVar(&delimiter, "delimiter", ",", "CSV: field delimiter (use tab for TSV)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: translate -from LANG -to LANG[,LANG...] [flags] [text]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts.targetLangs = splitList(targets)
+	opts.columns = splitList(columns)
+	if opts.sourceLang == "" || len(opts.targetLangs) == 0 {
+		flag.Usage()
+		return errors.New("-from and -to are required")
+	}
+	for _, lang := range append([]string{opts.sourceLang}, opts.targetLangs...) {
+		if !constants.IsValidLanguage(lang) {
+			return fmt.Errorf("language '%s' is not supported", lang)
+		}
+	}
+	if opts.concurrency < 1 {
+		opts.concurrency = 1
+	}
+	switch delimiter {
+	case "tab", `\t`:
+		opts.delimiter = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size == 0 || size != len(delimiter) {
+			return fmt.Errorf("delimiter '%s' is not valid, use a single character", delimiter)
+		}
+		opts.delimiter = r
+	}
+
+	input, err := readInput(&opts, flag.Args())
+	if err != nil {
+		return err
+	}
+
+	var b backend = directBackend{}
+	if opts.server != "" {
+		b = newServerBackend(opts.server, opts.apiKey)
+	}
+
+	switch opts.format {
+	case formatJSON:
+		root, err := parseJSONLocale(input)
+		if err != nil {
+			return err
+		}
+		return perTarget(opts, countTexts(root.stringNodes()), b, func(targetLang string) ([]byte, error) {
+			return translateJSONLocale(b, root, opts.sourceLang, targetLang, opts.concurrency)
+		})
+
+	case formatPO:
+		entries := parsePO(input)
+		var texts []string
+		for _, entry := range entries {
+			if entry.needsTranslation() {
+				texts = append(texts, entry.msgid)
+				if entry.hasPlural {
+					texts = append(texts, entry.msgidPlural)
+				}
+			}
+		}
+		return perTarget(opts, summarise(texts), b, func(targetLang string) ([]byte, error) {
+			return translatePO(b, entries, opts.sourceLang, targetLang, opts.concurrency)
+		})
+
+	case formatCSV:
+		return translateCSVFile(opts, input, b)
+
+	case formatMarkdown:
+		return perTarget(opts, summarise([]string{string(input)}), b, func(targetLang string) ([]byte, error) {
+			translated, err := b.translateMarkdown(string(input), opts.sourceLang, targetLang)
+			return []byte(translated), err
+		})
+
+	default:
+		text := strings.TrimSpace(string(input))
+		if text == "" {
+			return errors.New("nothing to translate")
+		}
+		return perTarget(opts, summarise([]string{text}), b, func(targetLang string) ([]byte, error) {
+			translated, err := b.translateTexts([]string{text}, opts.sourceLang, targetLang)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(translated[0] + "\n"), nil
+		})
+	}
+}
+
+// readInput reads the text to translate from the arguments, the -f file or
+// standard input, and settles the input format
+func readInput(opts *options, args []string) ([]byte, error) {
+	var input []byte
+	var err error
+	switch {
+	case opts.inputPath != "" && len(args) > 0:
+		return nil, errors.New("pass either text arguments or -f, not both")
+	case opts.inputPath == "-":
+		input, err = io.ReadAll(os.Stdin)
+	case opts.inputPath != "":
+		input, err = os.ReadFile(opts.inputPath)
+	case len(args) > 0:
+		input = []byte(strings.Join(args, " "))
+	default:
+		if stat, statErr := os.Stdin.Stat(); statErr == nil && stat.Mode()&os.ModeCharDevice != 0 {
+			flag.Usage()
+			return nil, errors.New("no input: pass text, -f FILE, or pipe text on standard input")
+		}
+		input, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.format == "" {
+		opts.format = formatText
+		switch strings.ToLower(filepath.Ext(opts.inputPath)) {
+		case ".json":
+			opts.format = formatJSON
+		case ".po", ".pot":
+			opts.format = formatPO
+		case ".csv":
+			opts.format = formatCSV
+		case ".tsv":
+			opts.format = formatCSV
+			opts.delimiter = '\t'
+		case ".md", ".markdown":
+			opts.format = formatMarkdown
+		}
+	}
+	switch opts.format {
+	case formatText, formatJSON, formatPO, formatCSV, formatMarkdown:
+	default:
+		return nil, fmt.Errorf("format '%s' is not supported, use text, json, po, csv or markdown", opts.format)
+	}
+	return input, nil
+}
+
+// perTarget translates the input into every target language, up to
+// concurrency languages at a time, and writes each result. In a dry run it
+// only prints the summary.
+func perTarget(opts options, summary string, b backend, translate func(targetLang string) ([]byte, error)) error {
+	if opts.dryRun {
+		fmt.Fprintf(os.Stderr, "dry run: would translate %s from %s into %s via %s\n",
+			summary, opts.sourceLang, strings.Join(opts.targetLangs, ", "), b.name())
+		return nil
+	}
+	if len(opts.targetLangs) > 1 && opts.outputPath == "" && opts.format != formatText {
+		return errors.New("several target languages need -o, e.g. -o out.{lang}" + filepath.Ext(opts.inputPath))
+	}
+
+	results := make([][]byte, len(opts.targetLangs))
+	errs := make([]error, len(opts.targetLangs))
+	sem := make(chan struct{}, opts.concurrency)
+	var wg sync.WaitGroup
+	for i, targetLang := range opts.targetLangs {
+		wg.Add(1)
+		go func(i int, targetLang string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = translate(targetLang)
+		}(i, targetLang)
+	}
+	wg.Wait()
+
+	for i, targetLang := range opts.targetLangs {
+		if errs[i] != nil {
+			return fmt.Errorf("%s: %w", targetLang, errs[i])
+		}
+		if opts.outputPath == "" && len(opts.targetLangs) > 1 {
+			fmt.Printf("[%s] %s", targetLang, results[i])
+			continue
+		}
+		if err := writeOutput(outputPathFor(opts, targetLang), results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translateCSVFile adds translated columns for every target language to one
+// CSV file, like POST /v1/translate/csv
+func translateCSVFile(opts options, input []byte, b backend) error {
+	if len(opts.columns) == 0 {
+		return errors.New("-columns is required for CSV files")
+	}
+
+	if opts.dryRun {
+		reader := csv.NewReader(strings.NewReader(string(input)))
+		reader.Comma = opts.delimiter
+		records, err := reader.ReadAll()
+		if err != nil {
+			return fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if len(records) == 0 {
+			return errors.New("CSV file is empty")
+		}
+		var texts []string
+		for _, column := range opts.columns {
+			index := -1
+			for j, name := range records[0] {
+				if name == column {
+					index = j
+				}
+			}
+			if index < 0 {
+				return fmt.Errorf("column not found in CSV header: %s", column)
+			}
+			for _, record := range records[1:] {
+				if index < len(record) {
+					texts = append(texts, record[index])
+				}
+			}
+		}
+		fmt.Fprintf(os.Stderr, "dry run: would translate %s in %d rows from %s into %s via %s\n",
+			summarise(texts), len(records)-1, opts.sourceLang, strings.Join(opts.targetLangs, ", "), b.name())
+		return nil
+	}
+
+	output, err := b.translateCSV(input, services.CSVOptions{
+		Delimiter:   opts.delimiter,
+		Columns:     opts.columns,
+		SourceLang:  opts.sourceLang,
+		TargetLangs: opts.targetLangs,
+	})
+	if err != nil {
+		return err
+	}
+	return writeOutput(strings.ReplaceAll(opts.outputPath, "{lang}", strings.Join(opts.targetLangs, "-")), output)
+}
+
+// outputPathFor returns where a target language's result goes. With several
+// targets and no {lang} in the path, the language is added before the extension.
+func outputPathFor(opts options, targetLang string) string {
+	path := opts.outputPath
+	if path == "" {
+		return ""
+	}
+	if strings.Contains(path, "{lang}") {
+		return strings.ReplaceAll(path, "{lang}", targetLang)
+	}
+	if len(opts.targetLangs) > 1 {
+		ext := filepath.Ext(path)
+		return strings.TrimSuffix(path, ext) + "." + targetLang + ext
+	}
+	return path
+}
+
+// writeOutput writes a result to a file, or to standard output for ""
+func writeOutput(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", path)
+	return nil
+}
+
+// countTexts summarises the string values of a JSON locale file
+func countTexts(nodes []*jsonNode) string {
+	texts := make([]string, len(nodes))
+	for i, node := range nodes {
+		texts[i] = node.text
+	}
+	return summarise(texts)
+}
+
+// summarise describes how much text would be sent, for dry runs
+func summarise(texts []string) string {
+	count, chars := 0, 0
+	for _, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			count++
+			chars += utf8.RuneCountInString(text)
+		}
+	}
+	noun := "strings"
+	if count == 1 {
+		noun = "string"
+	}
+	return fmt.Sprintf("%d %s (%d characters)", count, noun, chars)
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,28 +1,170 @@
 package constants
 
-// SupportedLanguages returns the list of supported language codes (ISO-639)
-// These are used for frontend dropdowns and i18n localization
-var SupportedLanguages = []string{"en", "hi", "mr", "ta", "te", "gu", "pa", "or", "ml"}
-
-// LanguageNames maps language codes to their display names
-var LanguageNames = map[string]string{
-	"en": "English",
-	"hi": "Hindi",
-	"mr": "Marathi",
-	"ta": "Tamil",
-	"te": "Telugu",
-	"gu": "Gujarati",
-	"pa": "Punjabi",
-	"or": "Odia",
-	"ml": "Malayalam",
-}
-
-// IsValidLanguage checks if a language code is supported
-func IsValidLanguage(langCode string) bool {
-	for _, lang := range SupportedLanguages {
-		if lang == langCode {
+import "strings"
+
+// Language is a language Bhashini translates: English and the 22 languages of
+// the Eighth Schedule
+type Language struct {
+	Code    string   // ISO-639 code Bhashini uses
+	Name    string   // English display name
+	Scripts []string // ISO-15924 scripts it is written in, the default first
+}
+
+// Script returns the script the language is written in by default
+func (l Language) Script() string {
+	return l.Scripts[0]
+}
+
+// HasScript reports whether the language is written in a script
+func (l Language) HasScript(script string) bool {
+	for _, s := range l.Scripts {
+		if s == script {
 			return true
 		}
 	}
 	return false
 }
+
+// Tag returns the BCP-47 tag of the language written in a script, such as
+// "mni-Beng". The default script is left out, as in "hi".
+func (l Language) Tag(script string) string {
+	if script == "" || script == l.Script() {
+		return l.Code
+	}
+	return l.Code + "-" + script
+}
+
+// Languages lists the supported languages (ISO-639 codes)
+// These are used for frontend dropdowns and i18n localization
+var Languages = []Language{
+	{Code: "en", Name: "English", Scripts: []string{"Latn"}},
+	{Code: "hi", Name: "Hindi", Scripts: []string{"Deva"}},
+	{Code: "mr", Name: "Marathi", Scripts: []string{"Deva"}},
+	{Code: "ta", Name: "Tamil", Scripts: []string{"Taml"}},
+	{Code: "te", Name: "Telugu", Scripts: []string{"Telu"}},
+	{Code: "gu", Name: "Gujarati", Scripts: []string{"Gujr"}},
+	{Code: "pa", Name: "Punjabi", Scripts: []string{"Guru"}},
+	{Code: "or", Name: "Odia", Scripts: []string{"Orya"}},
+	{Code: "ml", Name: "Malayalam", Scripts: []string{"Mlym"}},
+	{Code: "bn", Name: "Bengali", Scripts: []string{"Beng"}},
+	{Code: "as", Name: "Assamese", Scripts: []string{"Beng"}},
+	{Code: "kn", Name: "Kannada", Scripts: []string{"Knda"}},
+	{Code: "ur", Name: "Urdu", Scripts: []string{"Arab"}},
+	{Code: "ne", Name: "Nepali", Scripts: []string{"Deva"}},
+	{Code: "sa", Name: "Sanskrit", Scripts: []string{"Deva"}},
+	{Code: "sat", Name: "Santali", Scripts: []string{"Olck"}},
+	{Code: "mni", Name: "Manipuri", Scripts: []string{"Mtei", "Beng"}},
+	{Code: "gom", Name: "Konkani", Scripts: []string{"Deva"}},
+	{Code: "mai", Name: "Maithili", Scripts: []string{"Deva"}},
+	{Code: "doi", Name: "Dogri", Scripts: []string{"Deva"}},
+	{Code: "brx", Name: "Bodo", Scripts: []string{"Deva"}},
+	{Code: "ks", Name: "Kashmiri", Scripts: []string{"Arab", "Deva"}},
+	{Code: "sd", Name: "Sindhi", Scripts: []string{"Arab", "Deva"}},
+}
+
+// languageAliases maps other ISO-639 codes of the languages to the codes
+// Bhashini uses
+var languageAliases = map[string]string{
+	"eng": "en", "hin": "hi", "mar": "mr", "tam": "ta", "tel": "te",
+	"guj": "gu", "pan": "pa", "ori": "or", "ory": "or", "mal": "ml",
+	"ben": "bn", "asm": "as", "kan": "kn", "urd": "ur", "nep": "ne",
+	"npi": "ne", "san": "sa", "kok": "gom", "kas": "ks", "snd": "sd",
+}
+
+// languagesByCode indexes Languages by code and alias
+var languagesByCode = func() map[string]Language {
+	byCode := make(map[string]Language, len(Languages)+len(languageAliases))
+	for _, language := range Languages {
+		byCode[language.Code] = language
+	}
+	for alias, code := range languageAliases {
+		byCode[alias] = byCode[code]
+	}
+	return byCode
+}()
+
+// ParseLanguage parses a language code or BCP-47 tag such as "hi", "hi-IN",
+// "sat-Olck" or "mni_Beng_IN" into its language and script. The script is
+// the language's default when the tag has none; a region is ignored.
+func ParseLanguage(tag string) (Language, string, bool) {
+	subtags := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	if len(subtags) == 0 || len(subtags) > 3 {
+		return Language{}, "", false
+	}
+	language, ok := languagesByCode[strings.ToLower(subtags[0])]
+	if !ok {
+		return Language{}, "", false
+	}
+
+	script := language.Script()
+	rest := subtags[1:]
+	if len(rest) > 0 && len(rest[0]) == 4 {
+		script = strings.ToUpper(rest[0][:1]) + strings.ToLower(rest[0][1:])
+		if !language.HasScript(script) {
+			return Language{}, "", false
+		}
+		rest = rest[1:]
+	}
+	if len(rest) > 1 || (len(rest) == 1 && !isRegion(rest[0])) {
+		return Language{}, "", false
+	}
+	return language, script, true
+}
+
+// isRegion reports whether a subtag is an ISO-3166 or UN M.49 region
+func isRegion(subtag string) bool {
+	switch len(subtag) {
+	case 2:
+		return strings.Trim(strings.ToUpper(subtag), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+	case 3:
+		return strings.Trim(subtag, "0123456789") == ""
+	}
+	return false
+}
+
+// NormalizeLanguage returns the canonical tag of a language code or BCP-47
+// tag: the Bhashini code, followed by the script when it is not the default,
+// as in "hi" for "hi-IN" and "mni-Beng" for "mni_Beng"
+func NormalizeLanguage(tag string) (string, bool) {
+	language, script, ok := ParseLanguage(tag)
+	if !ok {
+		return "", false
+	}
+	return language.Tag(script), true
+}
+
+// SplitLanguage splits a language tag into the Bhashini code and script, as
+// sent in compute requests. Unknown tags are split at their first hyphen.
+func SplitLanguage(tag string) (string, string) {
+	language, script, ok := ParseLanguage(tag)
+	if !ok {
+		code, script, _ := strings.Cut(tag, "-")
+		return code, script
+	}
+	return language.Code, script
+}
+
+// LanguageName returns the display name of a language tag, or an empty string
+// for unknown tags
+func LanguageName(tag string) string {
+	language, _, ok := ParseLanguage(tag)
+	if !ok {
+		return ""
+	}
+	return language.Name
+}
+
+// LanguageCodes returns the codes of the supported languages
+func LanguageCodes() []string {
+	codes := make([]string, len(Languages))
+	for i, language := range Languages {
+		codes[i] = language.Code
+	}
+	return codes
+}
+
+// IsValidLanguage checks if a language code or BCP-47 tag is supported
+func IsValidLanguage(langCode string) bool {
+	_, _, ok := ParseLanguage(langCode)
+	return ok
+}
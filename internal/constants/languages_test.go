@@ -0,0 +1,101 @@
+package constants
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"hi", "hi", true},
+		{"HI", "hi", true},
+		{"hi-IN", "hi", true},
+		{"hi_Deva_IN", "hi", true},
+		{"en-001", "en", true},
+		{"hin", "hi", true},
+		{"ory", "or", true},
+		{"kok", "gom", true},
+		{"kok-Deva", "gom", true},
+		{"sat-Olck", "sat", true},
+		{"mni", "mni", true},
+		{"mni-Mtei", "mni", true},
+		{"mni_beng", "mni-Beng", true},
+		{"mni-Beng-IN", "mni-Beng", true},
+		{"ks-Deva", "ks-Deva", true},
+		{"sd-Arab", "sd", true},
+		{"", "", false},
+		{"xx", "", false},
+		{"hi-Latn", "", false},
+		{"hi-IND", "", false},
+		{"hi-IN-x", "", false},
+		{"hi-Deva-IN-x", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeLanguage(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeLanguage(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		tag    string
+		code   string
+		script string
+	}{
+		{"hi-IN", "hi", "Deva"},
+		{"kas", "ks", "Arab"},
+		{"ks-deva", "ks", "Deva"},
+		{"mni", "mni", "Mtei"},
+		{"npi_NP", "ne", "Deva"},
+	}
+	for _, tt := range tests {
+		language, script, ok := ParseLanguage(tt.tag)
+		if !ok || language.Code != tt.code || script != tt.script {
+			t.Errorf("ParseLanguage(%q) = %q, %q, %v, want %q, %q, true", tt.tag, language.Code, script, ok, tt.code, tt.script)
+		}
+	}
+}
+
+func TestSplitLanguage(t *testing.T) {
+	tests := []struct {
+		tag    string
+		code   string
+		script string
+	}{
+		{"hi", "hi", "Deva"},
+		{"kok", "gom", "Deva"},
+		{"mni-Beng", "mni", "Beng"},
+		{"xx-Latn", "xx", "Latn"},
+		{"xx", "xx", ""},
+	}
+	for _, tt := range tests {
+		code, script := SplitLanguage(tt.tag)
+		if code != tt.code || script != tt.script {
+			t.Errorf("SplitLanguage(%q) = %q, %q, want %q, %q", tt.tag, code, script, tt.code, tt.script)
+		}
+	}
+}
+
+func TestLanguagesAreConsistent(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, language := range Languages {
+		if seen[language.Code] {
+			t.Errorf("language %q listed twice", language.Code)
+		}
+		seen[language.Code] = true
+		if language.Name == "" || len(language.Scripts) == 0 {
+			t.Errorf("language %q has no name or scripts", language.Code)
+		}
+	}
+	for alias, code := range languageAliases {
+		if seen[alias] {
+			t.Errorf("alias %q shadows a language code", alias)
+		}
+		if !seen[code] {
+			t.Errorf("alias %q maps to unknown code %q", alias, code)
+		}
+	}
+}
@@ -2,7 +2,8 @@ package db
 
 import (
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -12,12 +13,12 @@ import (
 func Connect() (*sql.DB, error) {
 	// Load .env
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+		slog.Info("No .env file found, using system environment variables")
 	}
 
 	connStr := os.Getenv("DATABASE_URL")
 	if connStr == "" {
-		log.Fatal("DATABASE_URL not set")
+		return nil, errors.New("DATABASE_URL not set")
 	}
 
 	database, err := sql.Open("postgres", connStr)
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// CreateAPIKeyRequest represents a request for a new client API key
+type CreateAPIKeyRequest struct {
+	Name           string   `json:"name" validate:"required"`
+	Scopes         []string `json:"scopes" validate:"required"` // translate, manage
+	ExpiresIn      string   `json:"expires_in"`                 // Go duration, e.g. 720h; empty for no expiry
+	CallbackURL    string   `json:"callback_url"`               // default webhook for jobs created with the key
+	CallbackSecret string   `json:"callback_secret"`
+	models.APIKeyLimits
+}
+
+// RotateAPIKeyRequest represents a key rotation
+type RotateAPIKeyRequest struct {
+	GracePeriod string `json:"grace_period"` // how long the old key keeps working, e.g. 24h
+}
+
+// APIKeySecretResponse carries a new key. The key is only ever shown here.
+type APIKeySecretResponse struct {
+	Key string `json:"key"`
+	*models.APIKey
+}
+
+// CreateAPIKey creates a client API key and returns it once
+func CreateAPIKey(db *sql.DB) fiber.Handler {
+	// input:
+	// {"name": "editor-ui", "scopes": ["translate"], "expires_in": "8760h", "rate_limit": 10, "char_quota": 1000000, "char_quota_period": "month"}
+
+	return func(c *fiber.Ctx) error {
+		var req CreateAPIKeyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body: " + err.Error(),
+			})
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > 100 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "name is required and must be at most 100 characters",
+			})
+		}
+		if len(req.Scopes) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "scopes is required, use translate and/or manage",
+			})
+		}
+		for _, scope := range req.Scopes {
+			if scope != models.ScopeTranslate && scope != models.ScopeManage {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "scope '" + scope + "' is not valid, use translate or manage",
+				})
+			}
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresIn != "" {
+			expiresIn, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || expiresIn <= 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "expires_in must be a positive duration such as 720h",
+				})
+			}
+			at := time.Now().Add(expiresIn)
+			expiresAt = &at
+		}
+
+		if req.CallbackURL != "" {
+			if msg := validateCallbackURL(c.UserContext(), req.CallbackURL); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  msg,
+				})
+			}
+			if req.CallbackSecret == "" && services.DefaultWebhookSecret() == "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "callback_secret is required because no default webhook secret is configured",
+				})
+			}
+		}
+
+		if msg := validateAPIKeyLimits(req.APIKeyLimits); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		key, prefix, hash, err := services.GenerateAPIKey()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		keyRepo := repository.NewAPIKeyRepository(db)
+		apiKey, err := keyRepo.CreateKey(c.UserContext(), models.APIKey{
+			Name:           req.Name,
+			Prefix:         prefix,
+			Scopes:         req.Scopes,
+			CallbackURL:    req.CallbackURL,
+			CallbackSecret: req.CallbackSecret,
+			ExpiresAt:      expiresAt,
+			APIKeyLimits:   req.APIKeyLimits,
+		}, hash)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status": "success",
+			"data":   APIKeySecretResponse{Key: key, APIKey: apiKey},
+		})
+	}
+}
+
+// ListAPIKeys lists every key, including revoked and expired ones
+func ListAPIKeys(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keyRepo := repository.NewAPIKeyRepository(db)
+		keys, err := keyRepo.ListKeys(c.UserContext())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   keys,
+		})
+	}
+}
+
+// RevokeAPIKey revokes a key immediately
+func RevokeAPIKey(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return apiKeyLookupError(c, repository.ErrAPIKeyNotFound)
+		}
+
+		keyRepo := repository.NewAPIKeyRepository(db)
+		apiKey, err := keyRepo.RevokeKey(c.UserContext(), id)
+		if err != nil {
+			return apiKeyLookupError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   apiKey,
+		})
+	}
+}
+
+// SetAPIKeyLimits replaces a key's rate limit and character quota. Omitted
+// limits fall back to the service defaults.
+func SetAPIKeyLimits(db *sql.DB) fiber.Handler {
+	// input:
+	// {"rate_limit": 5, "char_quota": 50000, "char_quota_period": "day", "quota_exempt_cache_hits": true}
+
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return apiKeyLookupError(c, repository.ErrAPIKeyNotFound)
+		}
+
+		var limits models.APIKeyLimits
+		if err := c.BodyParser(&limits); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body: " + err.Error(),
+			})
+		}
+		if msg := validateAPIKeyLimits(limits); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		keyRepo := repository.NewAPIKeyRepository(db)
+		apiKey, err := keyRepo.SetLimits(c.UserContext(), id, limits)
+		if err != nil {
+			return apiKeyLookupError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   apiKey,
+		})
+	}
+}
+
+// RotateAPIKey replaces a key with a new one carrying the same name, scopes
+// and callback. The old key keeps working for the optional grace period.
+func RotateAPIKey(db *sql.DB) fiber.Handler {
+	// input:
+	// {"grace_period": "24h"}
+
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return apiKeyLookupError(c, repository.ErrAPIKeyNotFound)
+		}
+
+		var req RotateAPIKeyRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "Invalid request body: " + err.Error(),
+				})
+			}
+		}
+
+		var grace time.Duration
+		if req.GracePeriod != "" {
+			parsed, err := time.ParseDuration(req.GracePeriod)
+			if err != nil || parsed < 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "grace_period must be a duration such as 24h",
+				})
+			}
+			grace = parsed
+		}
+
+		key, prefix, hash, err := services.GenerateAPIKey()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		keyRepo := repository.NewAPIKeyRepository(db)
+		apiKey, err := keyRepo.RotateKey(c.UserContext(), id, prefix, hash, grace)
+		if err != nil {
+			return apiKeyLookupError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"status": "success",
+			"data":   APIKeySecretResponse{Key: key, APIKey: apiKey},
+		})
+	}
+}
+
+// apiKeyLookupError writes the response for a failed key lookup
+func apiKeyLookupError(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		code = fiber.StatusNotFound
+		err = errors.New("API key not found, or already revoked or expired")
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status": "error",
+		"error":  err.Error(),
+	})
+}
+
+// validateAPIKeyLimits checks a key's limits, returning an error message or an
+// empty string
+func validateAPIKeyLimits(limits models.APIKeyLimits) string {
+	if limits.RateLimit != nil && *limits.RateLimit < 0 {
+		return "rate_limit must be 0 (unlimited) or more"
+	}
+	if limits.CharQuota != nil && *limits.CharQuota < 0 {
+		return "char_quota must be 0 (unlimited) or more"
+	}
+	if period := limits.CharQuotaPeriod; period != nil && *period != models.QuotaPeriodDay && *period != models.QuotaPeriodMonth {
+		return "char_quota_period must be day or month"
+	}
+	return ""
+}
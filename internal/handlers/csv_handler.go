@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+	"user-service/internal/middleware"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TranslateCSV translates selected columns of a CSV file into one or more
+// target languages.
+//
+// The CSV is sent either as a multipart upload in the "file" field or as the
+// raw request body (Content-Type: text/csv). Options are passed as form fields
+// or query parameters:
+//
+//	source_lang   source language code
+//	target_langs  comma-separated target language codes, e.g. hi,ta
+//	columns       comma-separated header names to translate
+//	delimiter     field delimiter, default "," (use "tab" or "\t" for TSV)
+//
+// The response is the same CSV with a <column>_<lang> column appended for
+// every selected column and target language.
+func TranslateCSV(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sourceLang := formOrQuery(c, "source_lang")
+		targetLangs := splitList(formOrQuery(c, "target_langs"))
+		columns := splitList(formOrQuery(c, "columns"))
+
+		// Validate required fields
+		if sourceLang == "" || len(targetLangs) == 0 || len(columns) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "source_lang, target_langs, and columns are required",
+			})
+		}
+
+		delimiter, err := parseDelimiter(formOrQuery(c, "delimiter"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		// Validate the language pairs
+		for i := range targetLangs {
+			if msg := services.UpstreamLanguages().ResolvePair(&sourceLang, &targetLangs[i]); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  msg,
+				})
+			}
+		}
+
+		// Read from the uploaded file when there is one, otherwise from the body
+		var input io.Reader
+		filename := "translated.csv"
+		if fileHeader, err := c.FormFile("file"); err == nil {
+			file, err := fileHeader.Open()
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "failed to open uploaded file: " + err.Error(),
+				})
+			}
+			defer file.Close()
+			input = file
+			filename = strings.TrimSuffix(filepath.Base(fileHeader.Filename), filepath.Ext(fileHeader.Filename)) + "_translated.csv"
+		} else if stream := c.Context().RequestBodyStream(); stream != nil {
+			input = stream
+		} else {
+			input = bytes.NewReader(c.Body())
+		}
+
+		timeoutMS, msg := parseTimeoutMS(formOrQuery(c, "timeout_ms"))
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+		ctx, cancel, msg := requestContext(c, timeoutMS)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+		defer cancel()
+
+		// The output is spooled to disk so large files never sit in memory
+		output, err := os.CreateTemp("", "translate-csv-*.csv")
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+		os.Remove(output.Name())
+
+		// Initialize services
+		bhashiniClient := services.NewBhashiniClient()
+		cacheRepo := repository.NewTranslationRepository(db)
+		translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+		translationService.SetCaller(middleware.Caller(c))
+		csvTranslator := services.NewCSVTranslator(translationService)
+
+		err = csvTranslator.Translate(ctx, input, output, services.CSVOptions{
+			Delimiter:   delimiter,
+			Columns:     columns,
+			SourceLang:  sourceLang,
+			TargetLangs: targetLangs,
+		})
+		if err != nil {
+			output.Close()
+			code := fiber.StatusInternalServerError
+			if errors.Is(err, services.ErrUnknownCSVColumn) {
+				code = fiber.StatusBadRequest
+			}
+			return translationError(c, err, code)
+		}
+
+		size, err := output.Seek(0, io.SeekCurrent)
+		if err == nil {
+			_, err = output.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			output.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		markStale(c, translationService)
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+		return c.Status(fiber.StatusOK).SendStream(output, int(size))
+	}
+}
+
+// formOrQuery returns a form field, falling back to the query string
+func formOrQuery(c *fiber.Ctx, key string) string {
+	if value := c.FormValue(key); value != "" {
+		return value
+	}
+	return c.Query(key)
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseDelimiter parses a single-character CSV delimiter
+func parseDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("delimiter '%s' is not valid, use a single character", value)
+	}
+	return r, nil
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// disconnectPollInterval is how often a request's connection is checked for
+// a client that has gone away
+const disconnectPollInterval = 250 * time.Millisecond
+
+// errClientDisconnected is the cause of contexts cancelled because the
+// client closed its connection before the response was ready
+var errClientDisconnected = errors.New("client disconnected")
+
+// requestContext returns a context to translate a request in, with a deadline
+// timeoutMS from now when it is set. fasthttp never cancels the request's own
+// context, so the connection is watched instead and the context is cancelled
+// as soon as the client closes it. It returns an error message instead when
+// timeoutMS is out of range.
+func requestContext(c *fiber.Ctx, timeoutMS int) (context.Context, context.CancelFunc, string) {
+	parent, cancelCause := context.WithCancelCause(c.UserContext())
+	ctx, cancel, msg := withTimeout(parent, timeoutMS)
+	if msg != "" {
+		cancelCause(nil)
+		return nil, nil, msg
+	}
+
+	conn := c.Context().Conn()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(disconnectPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if closed, ok := connClosed(conn); !ok {
+					return
+				} else if closed {
+					cancelCause(errClientDisconnected)
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, func() {
+		close(done)
+		cancel()
+		cancelCause(nil)
+	}, ""
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package handlers
+
+import "net"
+
+// connClosed cannot tell whether the peer has closed a connection on this
+// platform, so requests are only cancelled by their timeout_ms deadline
+func connClosed(conn net.Conn) (closed, ok bool) {
+	return false, false
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package handlers
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// connClosed reports whether the peer has closed a connection, by peeking at
+// its socket without consuming any bytes still to be read. ok is false when
+// the connection is not a plain socket, such as a TLS connection.
+func connClosed(conn net.Conn) (closed, ok bool) {
+	sc, isSocket := conn.(syscall.Conn)
+	if !isSocket {
+		return false, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+
+	var n int
+	var peekErr error
+	buf := make([]byte, 1)
+	err = raw.Control(func(fd uintptr) {
+		n, _, peekErr = syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+	})
+	switch {
+	case err != nil:
+		return true, true
+	case errors.Is(peekErr, syscall.EAGAIN), errors.Is(peekErr, syscall.EWOULDBLOCK), errors.Is(peekErr, syscall.EINTR):
+		// Nothing to read: the client is still waiting for the response
+		return false, true
+	case peekErr != nil:
+		return true, true
+	}
+	// A zero-byte read is the end of the stream
+	return n == 0, true
+}
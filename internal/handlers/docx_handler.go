@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"user-service/internal/middleware"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// docxContentType is the media type of Word documents
+const docxContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// TranslateDOCX translates a Word document uploaded as multipart form data.
+// The file goes in the "file" field, with source_lang and target_lang as form
+// fields. The response is the translated .docx with run formatting, tables,
+// headers and footers preserved.
+func TranslateDOCX(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sourceLang := c.FormValue("source_lang")
+		targetLang := c.FormValue("target_lang")
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil || sourceLang == "" || targetLang == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "file, source_lang, and target_lang are required",
+			})
+		}
+
+		// Validate the language pair
+		if msg := services.UpstreamLanguages().ResolvePair(&sourceLang, &targetLang); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "failed to open uploaded file: " + err.Error(),
+			})
+		}
+		defer file.Close()
+
+		timeoutMS, msg := parseTimeoutMS(formOrQuery(c, "timeout_ms"))
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+		ctx, cancel, msg := requestContext(c, timeoutMS)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+		defer cancel()
+
+		// The output is spooled to disk so large files never sit in memory
+		output, err := os.CreateTemp("", "translate-docx-*.docx")
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+		os.Remove(output.Name())
+
+		// Initialize services
+		bhashiniClient := services.NewBhashiniClient()
+		cacheRepo := repository.NewTranslationRepository(db)
+		translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+		translationService.SetCaller(middleware.Caller(c))
+		docxTranslator := services.NewDOCXTranslator(translationService)
+
+		if err := docxTranslator.Translate(ctx, file, fileHeader.Size, output, sourceLang, targetLang); err != nil {
+			output.Close()
+			code := fiber.StatusInternalServerError
+			if errors.Is(err, services.ErrInvalidDOCX) {
+				code = fiber.StatusBadRequest
+			}
+			return translationError(c, err, code)
+		}
+
+		size, err := output.Seek(0, io.SeekCurrent)
+		if err == nil {
+			_, err = output.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			output.Close()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		name := strings.TrimSuffix(filepath.Base(fileHeader.Filename), filepath.Ext(fileHeader.Filename))
+		markStale(c, translationService)
+		c.Set(fiber.HeaderContentType, docxContentType)
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", name+"_"+targetLang+".docx"))
+		return c.Status(fiber.StatusOK).SendStream(output, int(size))
+	}
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"user-service/internal/metrics"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// CreateJobRequest represents an asynchronous job submission. Batch jobs carry
+// items; document jobs carry one document with its format and language pair.
+// An optional callback URL receives a signed webhook when the job finishes.
+type CreateJobRequest struct {
+	Type           string               `json:"type" validate:"required"` // batch or document
+	Items          []TranslateBatchItem `json:"items"`
+	Format         string               `json:"format"` // text, markdown or subtitles
+	Content        string               `json:"content"`
+	SourceLang     string               `json:"source_lang"`
+	TargetLang     string               `json:"target_lang"`
+	CallbackURL    string               `json:"callback_url"`
+	CallbackSecret string               `json:"callback_secret"`
+}
+
+// JobDocumentResult represents the result of a completed document job
+type JobDocumentResult struct {
+	Format     string `json:"format"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	Content    string `json:"content"`
+}
+
+// CreateJob queues a batch or document for asynchronous translation and
+// returns the job ID straight away
+func CreateJob(db *sql.DB) fiber.Handler {
+	// input:
+	// {"type": "batch", "items": [{"source_text": "Hello", "source_lang": "en", "target_lang": "hi"}]}
+	// {"type": "document", "format": "markdown", "content": "# Title", "source_lang": "en", "target_lang": "hi"}
+
+	return func(c *fiber.Ctx) error {
+		var req CreateJobRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body: " + err.Error(),
+			})
+		}
+
+		var items []models.TranslationJobItem
+		switch req.Type {
+		case models.JobTypeBatch:
+			if len(req.Items) == 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "items array is required and cannot be empty",
+				})
+			}
+			for i, item := range req.Items {
+				if msg := validateJobItem(item.SourceText, &item.SourceLang, &item.TargetLang); msg != "" {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"status": "error",
+						"error":  fmt.Sprintf("item[%d]: %s", i, msg),
+					})
+				}
+				items = append(items, models.TranslationJobItem{
+					SourceText: item.SourceText,
+					SourceLang: item.SourceLang,
+					TargetLang: item.TargetLang,
+				})
+			}
+			metrics.BatchItems.WithLabelValues(c.Route().Path).Observe(float64(len(items)))
+			req.Format = ""
+
+		case models.JobTypeDocument:
+			if req.Format == "" {
+				req.Format = models.DocumentFormatText
+			}
+			if req.Format != models.DocumentFormatText && req.Format != models.DocumentFormatMarkdown && req.Format != models.DocumentFormatSubtitles {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "format '" + req.Format + "' is not supported, use text, markdown or subtitles",
+				})
+			}
+			if msg := validateJobItem(req.Content, &req.SourceLang, &req.TargetLang); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  msg,
+				})
+			}
+			items = append(items, models.TranslationJobItem{
+				SourceText: req.Content,
+				SourceLang: req.SourceLang,
+				TargetLang: req.TargetLang,
+			})
+
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "type must be batch or document",
+			})
+		}
+
+		if req.CallbackURL != "" {
+			if msg := validateCallbackURL(c.UserContext(), req.CallbackURL); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  msg,
+				})
+			}
+			if req.CallbackSecret == "" && services.DefaultWebhookSecret() == "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "callback_secret is required because no default webhook secret is configured",
+				})
+			}
+		} else if req.CallbackSecret != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "callback_secret requires callback_url",
+			})
+		}
+
+		// Jobs without their own callback use their API key's
+		apiKey := middleware.APIKey(c)
+		if req.CallbackURL == "" && apiKey != nil && apiKey.CallbackURL != "" {
+			req.CallbackURL, req.CallbackSecret = apiKey.CallbackURL, apiKey.CallbackSecret
+		}
+		job := models.TranslationJob{Type: req.Type, DocumentFormat: req.Format, CallbackURL: req.CallbackURL}
+		if apiKey != nil {
+			job.APIKeyID = apiKey.ID
+		}
+
+		jobRepo := repository.NewJobRepository(db)
+		created, err := jobRepo.CreateJob(c.UserContext(), job, req.CallbackSecret, items)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"status": "success",
+			"data":   created,
+		})
+	}
+}
+
+// GetJob returns a job's status and progress
+func GetJob(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := findJob(c, db)
+		if err != nil {
+			return jobLookupError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   job,
+		})
+	}
+}
+
+// CancelJob cancels a queued or running job. Items that were already
+// translated are kept but the job will not produce a result.
+func CancelJob(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := findJob(c, db)
+		if err != nil {
+			return jobLookupError(c, err)
+		}
+
+		jobRepo := repository.NewJobRepository(db)
+		cancelled, err := jobRepo.CancelJob(c.UserContext(), job.ID, services.JobResultTTL())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+		if !cancelled {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status": "error",
+				"error":  "job has already finished",
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status":  "success",
+			"message": "Job cancelled",
+		})
+	}
+}
+
+// GetJobResult returns the translations of a completed job: the items of a
+// batch job, or the translated document of a document job
+func GetJobResult(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := findJob(c, db)
+		if err != nil {
+			return jobLookupError(c, err)
+		}
+
+		if job.Status != models.JobStatusCompleted {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status": "error",
+				"error":  "job is " + job.Status + ", results are only available once it has completed",
+			})
+		}
+
+		jobRepo := repository.NewJobRepository(db)
+		items, err := jobRepo.GetItems(c.UserContext(), job.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		if job.Type == models.JobTypeDocument && len(items) == 1 {
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"status": "success",
+				"data": JobDocumentResult{
+					Format:     job.DocumentFormat,
+					SourceLang: items[0].SourceLang,
+					TargetLang: items[0].TargetLang,
+					Content:    items[0].TranslatedText,
+				},
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   items,
+		})
+	}
+}
+
+// GetJobWebhooks returns the webhook deliveries of a job with their delivery log
+func GetJobWebhooks(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := findJob(c, db)
+		if err != nil {
+			return jobLookupError(c, err)
+		}
+
+		webhookRepo := repository.NewWebhookRepository(db)
+		deliveries, err := webhookRepo.ListDeliveries(c.UserContext(), job.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+		if deliveries == nil {
+			deliveries = []models.WebhookDelivery{}
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   deliveries,
+		})
+	}
+}
+
+// findJob loads the job named in the route, treating malformed IDs and jobs
+// created with another API key as not found. Keys with the manage scope see
+// every job.
+func findJob(c *fiber.Ctx, db *sql.DB) (*models.TranslationJob, error) {
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, repository.ErrJobNotFound
+	}
+
+	jobRepo := repository.NewJobRepository(db)
+	job, err := jobRepo.GetJob(c.UserContext(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := middleware.APIKey(c)
+	if apiKey != nil && !apiKey.HasScope(models.ScopeManage) && job.APIKeyID != apiKey.ID {
+		return nil, repository.ErrJobNotFound
+	}
+	return job, nil
+}
+
+// jobLookupError writes the response for a failed job lookup
+func jobLookupError(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	if errors.Is(err, repository.ErrJobNotFound) {
+		code = fiber.StatusNotFound
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status": "error",
+		"error":  err.Error(),
+	})
+}
+
+// validateJobItem checks one text and its language pair, normalising the
+// pair's codes in place, and returns an error message or an empty string
+func validateJobItem(sourceText string, sourceLang, targetLang *string) string {
+	if sourceText == "" || *sourceLang == "" || *targetLang == "" {
+		return "source text, source_lang, and target_lang are required"
+	}
+	return services.UpstreamLanguages().ResolvePair(sourceLang, targetLang)
+}
+
+// validateCallbackURL checks that a callback URL is an absolute http(s) URL
+// that does not point to an internal address, returning an error message or
+// an empty string
+func validateCallbackURL(ctx context.Context, callbackURL string) string {
+	if err := services.CheckWebhookURL(ctx, callbackURL); err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"user-service/internal/logging"
+	"user-service/internal/middleware"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TranslateJSONL translates a JSONL file of records, one per line:
+//
+//	{"id": "greeting-1", "source_text": "Hello", "source_lang": "en", "target_lang": "hi"}
+//
+// The file is sent either as a multipart upload in the "file" field or as the
+// raw request body (Content-Type: application/x-ndjson). The response is a
+// JSONL file with one result per input record, in the same order and with the
+// same IDs:
+//
+//	{"id": "greeting-1", "line": 1, "source_lang": "en", "target_lang": "hi", "translated_text": "नमस्ते"}
+//	{"id": 7, "line": 2, "error": "target_lang 'xx' is not supported"}
+//
+// Input is translated as it is read and each batch of results is streamed
+// to the client as soon as it is ready, so files of any size can be
+// processed. Once streaming has started the status is 200; a request that
+// runs out of time stops after the last finished batch.
+func TranslateJSONL(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Read from the uploaded file when there is one, otherwise from the body
+		var input io.Reader
+		var closeInput func() error
+		filename := "translated.jsonl"
+		if fileHeader, err := c.FormFile("file"); err == nil {
+			file, err := fileHeader.Open()
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "failed to open uploaded file: " + err.Error(),
+				})
+			}
+			input, closeInput = file, file.Close
+			filename = strings.TrimSuffix(filepath.Base(fileHeader.Filename), filepath.Ext(fileHeader.Filename)) + "_translated.jsonl"
+		} else if stream := c.Context().RequestBodyStream(); stream != nil {
+			input = stream
+		} else {
+			input = bytes.NewReader(c.Body())
+		}
+		// The input is read while the response streams, after the handler
+		// has returned
+		if closeInput == nil {
+			closeInput = func() error { return nil }
+		}
+
+		timeoutMS, msg := parseTimeoutMS(formOrQuery(c, "timeout_ms"))
+		if msg != "" {
+			closeInput()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		// Nothing can be rejected once the stream has started
+		reader := bufio.NewReaderSize(input, 64<<10)
+		if _, err := reader.Peek(1); err != nil {
+			closeInput()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "JSONL input is empty",
+			})
+		}
+
+		// The stream outlives the handler, so the context is cancelled when
+		// the stream ends, or as soon as a write to the client fails
+		ctx, cancel, msg := withTimeout(c.UserContext(), timeoutMS)
+		if msg != "" {
+			closeInput()
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		// Initialize services
+		bhashiniClient := services.NewBhashiniClient()
+		cacheRepo := repository.NewTranslationRepository(db)
+		translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+		translationService.SetCaller(middleware.Caller(c))
+		jsonlTranslator := services.NewJSONLTranslator(translationService)
+
+		logger := logging.FromContext(c.UserContext())
+		c.Set(fiber.HeaderContentType, contentTypeNDJSON)
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+		c.Set("X-Accel-Buffering", "no") // keep reverse proxies from buffering the stream
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer closeInput()
+			defer cancel()
+			stats, err := jsonlTranslator.Translate(ctx, reader, w)
+			if err != nil {
+				logger.Warn("JSONL translation stopped", "records", stats.Records, "error", err)
+			}
+		})
+
+		return nil
+	}
+}
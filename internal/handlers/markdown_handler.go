@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"database/sql"
+	"user-service/internal/middleware"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TranslateMarkdownRequest represents the Markdown translation request
+type TranslateMarkdownRequest struct {
+	Markdown   string `json:"markdown" validate:"required"`
+	SourceLang string `json:"source_lang" validate:"required"`
+	TargetLang string `json:"target_lang" validate:"required"`
+	// FrontMatterKeys selects the front-matter fields whose values are translated.
+	// Defaults to title and description; pass an empty list to translate none.
+	FrontMatterKeys *[]string `json:"front_matter_keys"`
+	TimeoutMS       int       `json:"timeout_ms"` // optional deadline for the translation
+}
+
+// TranslateMarkdownResponse represents the Markdown translation response
+type TranslateMarkdownResponse struct {
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	Markdown   string `json:"markdown"`
+}
+
+// TranslateMarkdown translates a CommonMark document, leaving code, links,
+// HTML blocks and front-matter keys intact
+func TranslateMarkdown(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req TranslateMarkdownRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body: " + err.Error(),
+			})
+		}
+
+		// Validate required fields
+		if req.Markdown == "" || req.SourceLang == "" || req.TargetLang == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "markdown, source_lang, and target_lang are required",
+			})
+		}
+
+		// Validate the language pair
+		if msg := services.UpstreamLanguages().ResolvePair(&req.SourceLang, &req.TargetLang); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		frontMatterKeys := services.DefaultFrontMatterKeys
+		if req.FrontMatterKeys != nil {
+			frontMatterKeys = *req.FrontMatterKeys
+		}
+
+		ctx, cancel, msg := requestContext(c, req.TimeoutMS)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+		defer cancel()
+
+		// Initialize services
+		bhashiniClient := services.NewBhashiniClient()
+		cacheRepo := repository.NewTranslationRepository(db)
+		translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+		translationService.SetCaller(middleware.Caller(c))
+		markdownTranslator := services.NewMarkdownTranslator(translationService)
+
+		translated, err := markdownTranslator.Translate(ctx, req.Markdown, req.SourceLang, req.TargetLang, frontMatterKeys)
+		if err != nil {
+			return translationError(c, err, fiber.StatusInternalServerError)
+		}
+
+		markStale(c, translationService)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data": TranslateMarkdownResponse{
+				SourceLang: req.SourceLang,
+				TargetLang: req.TargetLang,
+				Markdown:   translated,
+			},
+		})
+	}
+}
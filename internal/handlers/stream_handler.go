@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"user-service/internal/metrics"
+	"user-service/internal/middleware"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamBatchWorkers is how many items of a streamed batch are translated at once
+const streamBatchWorkers = 4
+
+// Content types of streamed batch responses
+const (
+	contentTypeEventStream = "text/event-stream"
+	contentTypeNDJSON      = "application/x-ndjson"
+)
+
+// BatchStreamItem is the event sent for each finished item of a streamed batch
+type BatchStreamItem struct {
+	Type           string `json:"type"` // always "item"
+	Index          int    `json:"index"`
+	SourceText     string `json:"source_text"`
+	SourceLang     string `json:"source_lang"`
+	TargetLang     string `json:"target_lang"`
+	TranslatedText string `json:"translated_text,omitempty"`
+	Stale          bool   `json:"stale,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// BatchStreamSummary is the final event of a streamed batch
+type BatchStreamSummary struct {
+	Type       string `json:"type"` // always "summary"
+	Total      int    `json:"total"`
+	Succeeded  int    `json:"succeeded"`
+	Failed     int    `json:"failed"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// TranslateBatchStream translates a batch like TranslateBatch but streams each
+// item's result as soon as it is ready, in completion order and tagged with the
+// item's index, followed by a summary event. A failed item is reported in its
+// own event and does not stop the rest of the batch.
+//
+// The response is Server-Sent Events when the client accepts text/event-stream
+// (or passes ?format=sse) and NDJSON otherwise:
+//
+//	event: item
+//	data: {"type":"item","index":1,"source_text":"World",...,"translated_text":"दुनिया"}
+//
+//	{"type":"item","index":1,"source_text":"World",...,"translated_text":"दुनिया"}
+//	{"type":"summary","total":2,"succeeded":2,"failed":0,"duration_ms":812}
+func TranslateBatchStream(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req TranslateBatchRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body: " + err.Error(),
+			})
+		}
+
+		// Validate everything up front; once streaming starts the status is 200
+		if len(req.Items) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "items array is required and cannot be empty",
+			})
+		}
+		for i := range req.Items {
+			item := &req.Items[i]
+			if msg := validateJobItem(item.SourceText, &item.SourceLang, &item.TargetLang); msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  fmt.Sprintf("item[%d]: %s", i, msg),
+				})
+			}
+		}
+
+		// The stream outlives the handler, so the context is cancelled when
+		// the stream ends, or as soon as the client goes away
+		ctx, cancel, msg := withTimeout(c.UserContext(), req.TimeoutMS)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		sse := c.Query("format") == "sse" ||
+			(c.Query("format") == "" && strings.Contains(c.Get(fiber.HeaderAccept), contentTypeEventStream))
+		if sse {
+			c.Set(fiber.HeaderContentType, contentTypeEventStream)
+		} else {
+			c.Set(fiber.HeaderContentType, contentTypeNDJSON)
+		}
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set("X-Accel-Buffering", "no") // keep reverse proxies from buffering the stream
+
+		items := req.Items
+		metrics.BatchItems.WithLabelValues(c.Route().Path).Observe(float64(len(items)))
+		caller := middleware.Caller(c)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer cancel()
+			start := time.Now()
+			results := make(chan BatchStreamItem)
+			stop := make(chan struct{})
+			next := make(chan int)
+
+			// Hand out item indexes until the batch is done or the client has gone
+			go func() {
+				defer close(next)
+				for i := range items {
+					select {
+					case next <- i:
+					case <-stop:
+						return
+					}
+				}
+			}()
+
+			var wg sync.WaitGroup
+			for n := 0; n < streamBatchWorkers && n < len(items); n++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					// Each worker has its own service; services are not safe for concurrent use
+					bhashiniClient := services.NewBhashiniClient()
+					cacheRepo := repository.NewTranslationRepository(db)
+					translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+					translationService.SetCaller(caller)
+
+					for i := range next {
+						item := items[i]
+						result := BatchStreamItem{
+							Type:       "item",
+							Index:      i,
+							SourceText: item.SourceText,
+							SourceLang: item.SourceLang,
+							TargetLang: item.TargetLang,
+						}
+						stale := translationService.StaleSentences()
+						translatedText, err := translationService.Translate(ctx, item.SourceText, item.SourceLang, item.TargetLang)
+						if err != nil {
+							result.Error = err.Error()
+						} else {
+							result.TranslatedText = translatedText
+							result.Stale = translationService.StaleSentences() > stale
+						}
+
+						select {
+						case results <- result:
+						case <-stop:
+							return
+						}
+					}
+				}()
+			}
+			go func() {
+				wg.Wait()
+				close(results)
+			}()
+
+			summary := BatchStreamSummary{Type: "summary", Total: len(items)}
+			for result := range results {
+				if result.Error != "" {
+					summary.Failed++
+				} else {
+					summary.Succeeded++
+				}
+				if err := writeStreamEvent(w, sse, result.Type, result); err != nil {
+					// The client disconnected; stop handing out work and
+					// abandon the items in flight
+					close(stop)
+					cancel()
+					return
+				}
+			}
+
+			summary.DurationMs = time.Since(start).Milliseconds()
+			writeStreamEvent(w, sse, summary.Type, summary)
+		})
+
+		return nil
+	}
+}
+
+// writeStreamEvent writes one event as an SSE message or an NDJSON line and
+// flushes it to the client
+func writeStreamEvent(w *bufio.Writer, sse bool, eventType string, event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if sse {
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+	} else {
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"user-service/internal/middleware"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TranslateSubtitlesRequest represents the subtitle translation request
+type TranslateSubtitlesRequest struct {
+	Subtitles  string `json:"subtitles" validate:"required"`
+	Format     string `json:"format"` // srt or vtt, detected from the header when empty
+	SourceLang string `json:"source_lang" validate:"required"`
+	TargetLang string `json:"target_lang" validate:"required"`
+	TimeoutMS  int    `json:"timeout_ms"` // optional deadline for the translation
+}
+
+// TranslateSubtitlesResponse represents the subtitle translation response
+type TranslateSubtitlesResponse struct {
+	Format     string `json:"format"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	Subtitles  string `json:"subtitles"`
+}
+
+// TranslateSubtitles translates the cue text of an SRT or WebVTT file while
+// keeping cue IDs, timings, settings and styling tags unchanged
+func TranslateSubtitles(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req TranslateSubtitlesRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "Invalid request body: " + err.Error(),
+			})
+		}
+
+		// Validate required fields
+		if req.Subtitles == "" || req.SourceLang == "" || req.TargetLang == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "subtitles, source_lang, and target_lang are required",
+			})
+		}
+
+		if req.Format == "" {
+			req.Format = services.DetectSubtitleFormat(req.Subtitles)
+		}
+		if req.Format != services.SubtitleFormatSRT && req.Format != services.SubtitleFormatVTT {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "format '" + req.Format + "' is not supported, use srt or vtt",
+			})
+		}
+
+		// Validate the language pair
+		if msg := services.UpstreamLanguages().ResolvePair(&req.SourceLang, &req.TargetLang); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+
+		ctx, cancel, msg := requestContext(c, req.TimeoutMS)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+		defer cancel()
+
+		// Initialize services
+		bhashiniClient := services.NewBhashiniClient()
+		cacheRepo := repository.NewTranslationRepository(db)
+		translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+		translationService.SetCaller(middleware.Caller(c))
+		subtitleTranslator := services.NewSubtitleTranslator(translationService)
+
+		translated, err := subtitleTranslator.Translate(ctx, req.Subtitles, req.SourceLang, req.TargetLang)
+		if err != nil {
+			code := fiber.StatusInternalServerError
+			if errors.Is(err, services.ErrNoSubtitleCues) {
+				code = fiber.StatusBadRequest
+			}
+			return translationError(c, err, code)
+		}
+
+		markStale(c, translationService)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data": TranslateSubtitlesResponse{
+				Format:     req.Format,
+				SourceLang: req.SourceLang,
+				TargetLang: req.TargetLang,
+				Subtitles:  translated,
+			},
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// maxTimeoutMS is the longest deadline a request may set with timeout_ms
+const maxTimeoutMS = 10 * 60 * 1000
+
+// errorCodeTimeout is the error code of translations that ran past their
+// timeout_ms deadline
+const errorCodeTimeout = "timeout"
+
+// withTimeout returns a cancellable context to translate a request in, with
+// a deadline timeoutMS from now when it is set. It returns an error message
+// instead when timeoutMS is out of range.
+func withTimeout(parent context.Context, timeoutMS int) (context.Context, context.CancelFunc, string) {
+	if timeoutMS < 0 || timeoutMS > maxTimeoutMS {
+		return nil, nil, fmt.Sprintf("timeout_ms must be between 0 and %d", maxTimeoutMS)
+	}
+	if timeoutMS == 0 {
+		ctx, cancel := context.WithCancel(parent)
+		return ctx, cancel, ""
+	}
+	ctx, cancel := context.WithTimeout(parent, time.Duration(timeoutMS)*time.Millisecond)
+	return ctx, cancel, ""
+}
+
+// parseTimeoutMS reads timeout_ms from a form field or query parameter,
+// returning zero when it is not set or an error message when it is not a
+// number
+func parseTimeoutMS(value string) (int, string) {
+	if value == "" {
+		return 0, ""
+	}
+	timeoutMS, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, "timeout_ms must be a number of milliseconds"
+	}
+	return timeoutMS, ""
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseTimeoutMS(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		msg   string
+	}{
+		{"", 0, ""},
+		{"1500", 1500, ""},
+		{"-1", -1, ""}, // rejected by withTimeout
+		{"1.5s", 0, "timeout_ms must be a number of milliseconds"},
+	}
+	for _, tt := range tests {
+		got, msg := parseTimeoutMS(tt.value)
+		if got != tt.want || msg != tt.msg {
+			t.Errorf("parseTimeoutMS(%q) = %d, %q, want %d, %q", tt.value, got, msg, tt.want, tt.msg)
+		}
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	for _, timeoutMS := range []int{-1, maxTimeoutMS + 1} {
+		if _, _, msg := withTimeout(context.Background(), timeoutMS); msg == "" {
+			t.Errorf("withTimeout(%d) accepted an out of range timeout", timeoutMS)
+		}
+	}
+
+	ctx, cancel, msg := withTimeout(context.Background(), 0)
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("withTimeout(0) set a deadline")
+	}
+	cancel()
+	if ctx.Err() == nil {
+		t.Error("withTimeout(0) returned a context that cannot be cancelled")
+	}
+
+	ctx, cancel, msg = withTimeout(context.Background(), 1500)
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > 1500*time.Millisecond || time.Until(deadline) < time.Second {
+		t.Errorf("withTimeout(1500) deadline = %v, %v", deadline, ok)
+	}
+}
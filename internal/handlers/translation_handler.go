@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"user-service/internal/constants"
+	"user-service/internal/metrics"
+	"user-service/internal/middleware"
 	"user-service/internal/repository"
 	"user-service/internal/services"
 
@@ -15,6 +19,7 @@ type TranslateRequest struct {
 	SourceText string `json:"source_text" validate:"required"`
 	SourceLang string `json:"source_lang" validate:"required"`
 	TargetLang string `json:"target_lang" validate:"required"`
+	TimeoutMS  int    `json:"timeout_ms"` // optional deadline for the translation
 }
 
 // TranslateResponse represents the translation response
@@ -23,6 +28,7 @@ type TranslateResponse struct {
 	SourceLang     string `json:"source_lang"`
 	TargetLang     string `json:"target_lang"`
 	TranslatedText string `json:"translated_text"`
+	Stale          bool   `json:"stale,omitempty"`
 }
 
 // Translate handles translation requests
@@ -44,32 +50,33 @@ func Translate(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		// Validate language codes
-		if !constants.IsValidLanguage(req.SourceLang) {
+		// Validate the language pair
+		if msg := services.UpstreamLanguages().ResolvePair(&req.SourceLang, &req.TargetLang); msg != "" {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"status": "error",
-				"error":  "source_lang '" + req.SourceLang + "' is not supported",
+				"error":  msg,
 			})
 		}
-		if !constants.IsValidLanguage(req.TargetLang) {
+
+		ctx, cancel, msg := requestContext(c, req.TimeoutMS)
+		if msg != "" {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"status": "error",
-				"error":  "target_lang '" + req.TargetLang + "' is not supported",
+				"error":  msg,
 			})
 		}
+		defer cancel()
 
 		// Initialize services
 		bhashiniClient := services.NewBhashiniClient()
 		cacheRepo := repository.NewTranslationRepository(db)
 		translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+		translationService.SetCaller(middleware.Caller(c))
 
 		// Perform translation
-		translatedText, err := translationService.Translate(req.SourceText, req.SourceLang, req.TargetLang)
+		translatedText, err := translationService.Translate(ctx, req.SourceText, req.SourceLang, req.TargetLang)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"status": "error",
-				"error":  err.Error(),
-			})
+			return translationError(c, err, fiber.StatusInternalServerError)
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -79,6 +86,7 @@ func Translate(db *sql.DB) fiber.Handler {
 				SourceLang:     req.SourceLang,
 				TargetLang:     req.TargetLang,
 				TranslatedText: translatedText,
+				Stale:          markStale(c, translationService),
 			},
 		})
 	}
@@ -93,7 +101,8 @@ type TranslateBatchItem struct {
 
 // TranslateBatchRequest represents the batch translation request
 type TranslateBatchRequest struct {
-	Items []TranslateBatchItem `json:"items" validate:"required"`
+	Items     []TranslateBatchItem `json:"items" validate:"required"`
+	TimeoutMS int                  `json:"timeout_ms"` // optional deadline for the whole batch
 }
 
 // TranslateBatchResponse represents the batch translation response
@@ -102,6 +111,7 @@ type TranslateBatchResponse struct {
 	SourceLangs     []string `json:"source_langs"`
 	TargetLangs     []string `json:"target_langs"`
 	TranslatedTexts []string `json:"translated_texts"`
+	Stale           bool     `json:"stale,omitempty"`
 }
 
 // TranslateBatch handles batch translation requests
@@ -140,7 +150,8 @@ func TranslateBatch(db *sql.DB) fiber.Handler {
 		}
 
 		// Validate each item
-		for i, item := range req.Items {
+		for i := range req.Items {
+			item := &req.Items[i]
 			if item.SourceText == "" || item.SourceLang == "" || item.TargetLang == "" {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 					"status": "error",
@@ -148,25 +159,31 @@ func TranslateBatch(db *sql.DB) fiber.Handler {
 				})
 			}
 
-			// Validate language codes
-			if !constants.IsValidLanguage(item.SourceLang) {
+			// Validate the language pair
+			if msg := services.UpstreamLanguages().ResolvePair(&item.SourceLang, &item.TargetLang); msg != "" {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 					"status": "error",
-					"error":  fmt.Sprintf("item[%d]: source_lang '%s' is not supported", i, item.SourceLang),
-				})
-			}
-			if !constants.IsValidLanguage(item.TargetLang) {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"status": "error",
-					"error":  fmt.Sprintf("item[%d]: target_lang '%s' is not supported", i, item.TargetLang),
+					"error":  fmt.Sprintf("item[%d]: %s", i, msg),
 				})
 			}
 		}
 
+		ctx, cancel, msg := requestContext(c, req.TimeoutMS)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  msg,
+			})
+		}
+		defer cancel()
+
+		metrics.BatchItems.WithLabelValues(c.Route().Path).Observe(float64(len(req.Items)))
+
 		// Initialize services
 		bhashiniClient := services.NewBhashiniClient()
 		cacheRepo := repository.NewTranslationRepository(db)
 		translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+		translationService.SetCaller(middleware.Caller(c))
 
 		// Prepare response arrays
 		sourceTexts := make([]string, len(req.Items))
@@ -176,12 +193,9 @@ func TranslateBatch(db *sql.DB) fiber.Handler {
 
 		// Perform translation for each item
 		for i, item := range req.Items {
-			translatedText, err := translationService.Translate(item.SourceText, item.SourceLang, item.TargetLang)
+			translatedText, err := translationService.Translate(ctx, item.SourceText, item.SourceLang, item.TargetLang)
 			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"status": "error",
-					"error":  fmt.Sprintf("item[%d]: %s", i, err.Error()),
-				})
+				return translationError(c, fmt.Errorf("item[%d]: %w", i, err), fiber.StatusInternalServerError)
 			}
 
 			sourceTexts[i] = item.SourceText
@@ -197,18 +211,57 @@ func TranslateBatch(db *sql.DB) fiber.Handler {
 				SourceLangs:     sourceLangs,
 				TargetLangs:     targetLangs,
 				TranslatedTexts: translatedTexts,
+				Stale:           markStale(c, translationService),
 			},
 		})
 	}
 }
 
-// Languages returns the list of supported languages (ISO-639 codes)
+// Languages returns the list of supported languages (ISO-639 codes), as
+// discovered from the Bhashini pipeline configs
 // Used by frontend for language dropdown and i18n localization
 func Languages(db *sql.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"status": "success",
-			"data":   constants.SupportedLanguages,
+			"data":   services.UpstreamLanguages().Languages(),
+		})
+	}
+}
+
+// LanguagePair is a supported language pair with its display names
+type LanguagePair struct {
+	services.LanguagePairInfo
+	SourceName string `json:"source_name,omitempty"`
+	TargetName string `json:"target_name,omitempty"`
+}
+
+// LanguagePairs returns the supported language pairs, one per pipeline that
+// translates them, with their scripts and the service and model used.
+// The source_lang and target_lang query parameters filter the pairs.
+func LanguagePairs(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sourceLang, targetLang := c.Query("source_lang"), c.Query("target_lang")
+		for _, lang := range []*string{&sourceLang, &targetLang} {
+			if canonical, ok := constants.NormalizeLanguage(*lang); ok {
+				*lang = canonical
+			}
+		}
+
+		pairs := []LanguagePair{}
+		for _, pair := range services.UpstreamLanguages().Pairs() {
+			if (sourceLang != "" && pair.SourceLang != sourceLang) || (targetLang != "" && pair.TargetLang != targetLang) {
+				continue
+			}
+			pairs = append(pairs, LanguagePair{
+				LanguagePairInfo: pair,
+				SourceName:       constants.LanguageName(pair.SourceLang),
+				TargetName:       constants.LanguageName(pair.TargetLang),
+			})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   pairs,
 		})
 	}
 }
@@ -217,8 +270,9 @@ func Languages(db *sql.DB) fiber.Handler {
 func CleanCache(db *sql.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		cacheRepo := repository.NewTranslationRepository(db)
+		translationService := services.NewTranslationService(services.NewBhashiniClient(), cacheRepo)
 
-		if err := cacheRepo.CleanExpiredTranslations(); err != nil {
+		if err := translationService.CleanExpiredCache(c.UserContext()); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"status": "error",
 				"error":  err.Error(),
@@ -231,3 +285,64 @@ func CleanCache(db *sql.DB) fiber.Handler {
 		})
 	}
 }
+
+// headerTranslationStale marks responses that include translations served
+// from expired cache entries
+const headerTranslationStale = "X-Translation-Stale"
+
+// markStale sets the stale header when the service has served expired cache
+// entries, reporting whether it did
+func markStale(c *fiber.Ctx, translationService *services.TranslationService) bool {
+	if translationService.StaleSentences() == 0 {
+		return false
+	}
+	c.Set(headerTranslationStale, "true")
+	return true
+}
+
+// errorCodeQuotaTooSmall is the error code of translations that need more
+// characters than the key's quota allows in a whole period
+const errorCodeQuotaTooSmall = "quota_too_small"
+
+// errorCodeUpstreamUnavailable is the error code of translations refused
+// because Bhashini's circuit is open
+const errorCodeUpstreamUnavailable = "upstream_unavailable"
+
+// translationError writes the response for a failed translation: 429 when the
+// key's character quota is used up, 413 when the translation would not fit in
+// the quota at all, 503 when Bhashini is failing and its circuit is open, 504
+// when the request's timeout_ms ran out, otherwise code
+func translationError(c *fiber.Ctx, err error, code int) error {
+	var quotaErr *services.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		return middleware.QuotaExceeded(c, quotaErr)
+	}
+	var quotaTooSmallErr *services.QuotaTooSmallError
+	if errors.As(err, &quotaTooSmallErr) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"status": "error",
+			"code":   errorCodeQuotaTooSmall,
+			"error":  err.Error(),
+		})
+	}
+	var circuitErr *services.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		c.Set(fiber.HeaderRetryAfter, middleware.RetryAfterSeconds(circuitErr.RetryAfter))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status": "error",
+			"code":   errorCodeUpstreamUnavailable,
+			"error":  err.Error(),
+		})
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+			"status": "error",
+			"code":   errorCodeTimeout,
+			"error":  err.Error(),
+		})
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status": "error",
+		"error":  err.Error(),
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTranslationError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantRetry  string
+		wantLimit  string
+	}{
+		{
+			name:       "quota used up",
+			err:        fmt.Errorf("translate: %w", &services.QuotaExceededError{Limit: 1000, Used: 990, Period: "day", RetryAfter: 90 * time.Second}),
+			wantStatus: fiber.StatusTooManyRequests,
+			wantRetry:  "90",
+			wantLimit:  "1000",
+		},
+		{
+			name:       "quota too small",
+			err:        &services.QuotaTooSmallError{Limit: 1000, Characters: 5000, Period: "day"},
+			wantStatus: fiber.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "circuit open",
+			err:        &services.CircuitOpenError{Endpoint: "compute", RetryAfter: 1500 * time.Millisecond},
+			wantStatus: fiber.StatusServiceUnavailable,
+			wantRetry:  "2",
+		},
+		{
+			name:       "timeout",
+			err:        fmt.Errorf("compute: %w", context.DeadlineExceeded),
+			wantStatus: fiber.StatusGatewayTimeout,
+		},
+		{
+			name:       "other",
+			err:        errors.New("API returned status 502"),
+			wantStatus: fiber.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return translationError(c, tt.err, fiber.StatusBadGateway)
+			})
+			resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get(fiber.HeaderRetryAfter); got != tt.wantRetry {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetry)
+			}
+			if got := resp.Header.Get("X-RateLimit-Limit"); got != tt.wantLimit {
+				t.Errorf("X-RateLimit-Limit = %q, want %q", got, tt.wantLimit)
+			}
+			if tt.wantLimit != "" && resp.Header.Get("X-RateLimit-Remaining") != "10" {
+				t.Errorf("X-RateLimit-Remaining = %q, want 10", resp.Header.Get("X-RateLimit-Remaining"))
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetCircuitBreakers reports the upstream circuit breakers: their
+// configuration and the state of the circuit of every Bhashini endpoint and
+// pipeline called since the service started
+func GetCircuitBreakers() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		config, circuits := services.UpstreamCircuitBreakers()
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data": fiber.Map{
+				"enabled":            config.Threshold > 0,
+				"threshold":          config.Threshold,
+				"cooldown_seconds":   config.Cooldown.Seconds(),
+				"half_open_requests": config.HalfOpenRequests,
+				"circuits":           circuits,
+			},
+		})
+	}
+}
+
+// GetPipelines reports the Bhashini pipeline routes of each language pair and
+// the health of every pipeline and pair used since the service started
+func GetPipelines() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		router := services.UpstreamPipelineRouter()
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data": fiber.Map{
+				"routes": router.Routes(),
+				"health": router.Health(),
+			},
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/internal/constants"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// defaultUsageGroupBy is the grouping of a usage report without group_by
+var defaultUsageGroupBy = []string{"client", "pair"}
+
+// GetUsage reports translation usage from the hourly rollups, as JSON or as
+// CSV with ?format=csv.
+//
+// Query parameters:
+//
+//	from, to      time range as RFC 3339 or YYYY-MM-DD in UTC, to exclusive; defaults to this month so far
+//	api_key_id    one client; "admin" for the admin key and unattributed work
+//	source_lang   one source language
+//	target_lang   one target language
+//	group_by      comma-separated: client, pair, pipeline, and one of hour, day or month (default client,pair)
+func GetUsage(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		now := time.Now().UTC()
+		filter := models.UsageFilter{
+			From:       time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC),
+			To:         now,
+			SourceLang: c.Query("source_lang"),
+			TargetLang: c.Query("target_lang"),
+			GroupBy:    defaultUsageGroupBy,
+		}
+
+		for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+			if value := c.Query(name); value != "" {
+				parsed, err := parseUsageTime(value)
+				if err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"status": "error",
+						"error":  name + " must be an RFC 3339 time or a YYYY-MM-DD date",
+					})
+				}
+				*dest = parsed
+			}
+		}
+		if !filter.From.Before(filter.To) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status": "error",
+				"error":  "from must be before to",
+			})
+		}
+
+		switch apiKeyID := c.Query("api_key_id"); apiKeyID {
+		case "":
+		case "admin":
+			filter.APIKeyID = repository.NilAPIKeyID
+		default:
+			if _, err := uuid.Parse(apiKeyID); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "api_key_id must be a key ID or admin",
+				})
+			}
+			filter.APIKeyID = apiKeyID
+		}
+
+		for name, lang := range map[string]*string{"source_lang": &filter.SourceLang, "target_lang": &filter.TargetLang} {
+			if *lang == "" {
+				continue
+			}
+			canonical, ok := constants.NormalizeLanguage(*lang)
+			if !ok {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  name + " '" + *lang + "' is not supported",
+				})
+			}
+			*lang = canonical
+		}
+
+		if groupBy := c.Query("group_by"); groupBy != "" {
+			filter.GroupBy = nil
+			seen := make(map[string]bool)
+			periods := 0
+			for _, group := range strings.Split(groupBy, ",") {
+				group = strings.TrimSpace(group)
+				if seen[group] {
+					continue
+				}
+				seen[group] = true
+				switch group {
+				case "client", "pair", "pipeline":
+				case "hour", "day", "month":
+					periods++
+				default:
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"status": "error",
+						"error":  "group_by '" + group + "' is not valid, use client, pair, pipeline, hour, day or month",
+					})
+				}
+				filter.GroupBy = append(filter.GroupBy, group)
+			}
+			if periods > 1 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status": "error",
+					"error":  "group_by may contain only one of hour, day or month",
+				})
+			}
+		}
+
+		usageRepo := repository.NewUsageRepository(db)
+		rows, err := usageRepo.Report(c.UserContext(), filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  err.Error(),
+			})
+		}
+
+		if c.Query("format") == "csv" || (c.Query("format") == "" && strings.Contains(c.Get(fiber.HeaderAccept), "text/csv")) {
+			data, err := usageCSV(filter.GroupBy, rows)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"status": "error",
+					"error":  err.Error(),
+				})
+			}
+			filename := fmt.Sprintf("usage_%s_%s.csv", filter.From.Format("20060102"), filter.To.Format("20060102"))
+			c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+			c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+			return c.Status(fiber.StatusOK).Send(data)
+		}
+
+		report := models.UsageReport{From: filter.From, To: filter.To, GroupBy: filter.GroupBy, Rows: rows}
+		for _, row := range rows {
+			report.Totals.Add(row.UsageCounts)
+		}
+		report.Totals.UsageRatios = report.Totals.Ratios()
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   report,
+		})
+	}
+}
+
+// parseUsageTime parses a report bound given as RFC 3339 or as a UTC date
+func parseUsageTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// usageCSV renders a usage report as CSV, one line per group
+func usageCSV(groupBy []string, rows []models.UsageReportRow) ([]byte, error) {
+	var header []string
+	for _, group := range groupBy {
+		switch group {
+		case "hour", "day", "month":
+			header = append(header, "period_start")
+		case "client":
+			header = append(header, "api_key_id", "api_key_name", "api_key_prefix")
+		case "pair":
+			header = append(header, "source_lang", "target_lang")
+		case "pipeline":
+			header = append(header, "pipeline_id")
+		}
+	}
+	header = append(header, "requests", "errors", "sentences", "cache_hits", "cache_hit_ratio", "characters",
+		"upstream_characters", "characters_saved", "upstream_calls", "avg_latency_ms", "avg_upstream_latency_ms")
+
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	writer.Write(header)
+	for _, row := range rows {
+		var record []string
+		for _, group := range groupBy {
+			switch group {
+			case "hour", "day", "month":
+				record = append(record, row.PeriodStart.Format(time.RFC3339))
+			case "client":
+				record = append(record, row.APIKeyID, row.APIKeyName, row.APIKeyPrefix)
+			case "pair":
+				record = append(record, row.SourceLang, row.TargetLang)
+			case "pipeline":
+				record = append(record, row.PipelineID)
+			}
+		}
+		record = append(record,
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.Errors, 10),
+			strconv.FormatInt(row.Sentences, 10),
+			strconv.FormatInt(row.CacheHits, 10),
+			strconv.FormatFloat(row.CacheHitRatio, 'f', 4, 64),
+			strconv.FormatInt(row.Characters, 10),
+			strconv.FormatInt(row.UpstreamCharacters, 10),
+			strconv.FormatInt(row.CharactersSaved, 10),
+			strconv.FormatInt(row.UpstreamCalls, 10),
+			strconv.FormatFloat(row.AvgLatencyMS, 'f', 1, 64),
+			strconv.FormatFloat(row.AvgUpstreamLatencyMS, 'f', 1, 64),
+		)
+		writer.Write(record)
+	}
+	writer.Flush()
+	return output.Bytes(), writer.Error()
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Limits of one WebSocket session
+const (
+	wsMaxMessageBytes = 64 << 10
+	wsMaxInFlight     = 8
+)
+
+// Locals keys carrying the upgrade request's API key, caller and context into
+// the session
+const (
+	wsAPIKeyLocal  = "wsAPIKey"
+	wsCallerLocal  = "wsCaller"
+	wsContextLocal = "wsContext"
+)
+
+// WSMessage is a message exchanged over the translation WebSocket. Clients
+// send "session", "translate" and "cancel" messages; the server answers with
+// "session", "translation", "cancelled" and "error" messages.
+type WSMessage struct {
+	Type           string `json:"type"`
+	ID             string `json:"id,omitempty"`
+	SourceLang     string `json:"source_lang,omitempty"`
+	TargetLang     string `json:"target_lang,omitempty"`
+	Text           string `json:"text,omitempty"`
+	TranslatedText string `json:"translated_text,omitempty"`
+	Stale          bool   `json:"stale,omitempty"`
+	TimeoutMS      int    `json:"timeout_ms,omitempty"` // optional deadline of a translate message
+	Error          string `json:"error,omitempty"`
+}
+
+// wsRequest is one translate message being worked on
+type wsRequest struct {
+	id         string
+	ctx        context.Context
+	stop       context.CancelFunc // abandons the request's upstream calls
+	cancelled  bool
+	sourceLang string
+	targetLang string
+	text       string
+}
+
+// wsSession is the state of one WebSocket connection
+type wsSession struct {
+	conn       *websocket.Conn
+	db         *sql.DB
+	limiter    *services.RateLimiter
+	apiKey     *models.APIKey
+	caller     services.Caller
+	ctx        context.Context // the upgrade request's ID and trace, for logs and spans
+	writeMu    sync.Mutex
+	mu         sync.Mutex
+	sourceLang string
+	targetLang string
+	inFlight   map[string]*wsRequest // latest request per correlation ID
+	running    int                   // requests still calling upstream, superseded ones included
+	wg         sync.WaitGroup
+}
+
+// TranslateWebSocket serves live translation over a WebSocket.
+//
+// A client opens a session with a language pair, either in the query string
+// (/v1/ws/translate?source_lang=en&target_lang=hi) or with a session message,
+// and then sends translate messages carrying a correlation ID:
+//
+//	-> {"type": "session", "source_lang": "en", "target_lang": "hi"}
+//	-> {"type": "translate", "id": "draft-1", "text": "Hello, how"}
+//	-> {"type": "translate", "id": "draft-1", "text": "Hello, how are you?"}
+//	<- {"type": "cancelled", "id": "draft-1", "text": "Hello, how"}
+//	<- {"type": "translation", "id": "draft-1", "text": "Hello, how are you?", "translated_text": "..."}
+//
+// A translate message supersedes any in-flight request with the same ID, so a
+// client can send every keystroke of a draft under one ID and only receive
+// the latest translation. Superseded and cancelled requests, and those still
+// running when the client disconnects, stop calling upstream. Requests with
+// different IDs run independently, each with an optional timeout_ms. The
+// session uses the same translation cache as the REST handlers, and every
+// translate message counts against the key's rate limit and quota like a REST
+// request.
+func TranslateWebSocket(db *sql.DB) fiber.Handler {
+	limiter := services.NewRateLimiter(db)
+
+	upgrade := websocket.New(func(conn *websocket.Conn) {
+		apiKey, _ := conn.Locals(wsAPIKeyLocal).(*models.APIKey)
+		caller, _ := conn.Locals(wsCallerLocal).(services.Caller)
+		ctx, _ := conn.Locals(wsContextLocal).(context.Context)
+		session := &wsSession{
+			conn:       conn,
+			db:         db,
+			limiter:    limiter,
+			apiKey:     apiKey,
+			caller:     caller,
+			ctx:        ctx,
+			sourceLang: conn.Query("source_lang"),
+			targetLang: conn.Query("target_lang"),
+			inFlight:   make(map[string]*wsRequest),
+		}
+		session.serve()
+	})
+
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{
+				"status": "error",
+				"error":  "this endpoint only accepts WebSocket connections",
+			})
+		}
+		c.Locals(wsAPIKeyLocal, middleware.APIKey(c))
+		c.Locals(wsCallerLocal, middleware.Caller(c))
+		c.Locals(wsContextLocal, c.UserContext())
+		return upgrade(c)
+	}
+}
+
+// serve reads messages until the client disconnects
+func (s *wsSession) serve() {
+	defer s.wg.Wait()
+	s.conn.SetReadLimit(wsMaxMessageBytes)
+
+	if s.sourceLang != "" || s.targetLang != "" {
+		if msg := validateLanguagePair(&s.sourceLang, &s.targetLang); msg != "" {
+			s.write(WSMessage{Type: "error", Error: msg})
+			s.sourceLang, s.targetLang = "", ""
+		} else {
+			s.write(WSMessage{Type: "session", SourceLang: s.sourceLang, TargetLang: s.targetLang})
+		}
+	}
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			// Closed by the client, or the message was over the size limit
+			s.cancelAll()
+			return
+		}
+
+		var msg WSMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.write(WSMessage{Type: "error", Error: "invalid message: " + err.Error()})
+			continue
+		}
+
+		switch msg.Type {
+		case "session":
+			s.openSession(msg)
+		case "translate":
+			s.translate(msg)
+		case "cancel":
+			s.cancel(msg.ID)
+		default:
+			s.write(WSMessage{Type: "error", ID: msg.ID, Error: "type must be session, translate or cancel"})
+		}
+	}
+}
+
+// openSession sets or changes the session's language pair
+func (s *wsSession) openSession(msg WSMessage) {
+	if errMsg := validateLanguagePair(&msg.SourceLang, &msg.TargetLang); errMsg != "" {
+		s.write(WSMessage{Type: "error", Error: errMsg})
+		return
+	}
+
+	s.mu.Lock()
+	s.sourceLang, s.targetLang = msg.SourceLang, msg.TargetLang
+	s.mu.Unlock()
+
+	s.write(WSMessage{Type: "session", SourceLang: msg.SourceLang, TargetLang: msg.TargetLang})
+}
+
+// translate starts a translate request, superseding the in-flight request
+// with the same ID
+func (s *wsSession) translate(msg WSMessage) {
+	if msg.ID == "" || strings.TrimSpace(msg.Text) == "" {
+		s.write(WSMessage{Type: "error", ID: msg.ID, Error: "id and text are required"})
+		return
+	}
+
+	if status := s.limiter.Allow(s.ctx, s.apiKey); status != nil && !status.Allowed {
+		s.write(WSMessage{Type: "error", ID: msg.ID, Error: "rate limit of " + strconv.Itoa(status.Limit) + " requests per second exceeded"})
+		return
+	}
+
+	s.mu.Lock()
+	if s.sourceLang == "" {
+		s.mu.Unlock()
+		s.write(WSMessage{Type: "error", ID: msg.ID, Error: "open a session with source_lang and target_lang first"})
+		return
+	}
+	if s.running >= wsMaxInFlight {
+		s.mu.Unlock()
+		s.write(WSMessage{Type: "error", ID: msg.ID, Error: "too many requests in flight, wait for a result before sending more"})
+		return
+	}
+
+	ctx, stop, errMsg := withTimeout(s.ctx, msg.TimeoutMS)
+	if errMsg != "" {
+		s.mu.Unlock()
+		s.write(WSMessage{Type: "error", ID: msg.ID, Error: errMsg})
+		return
+	}
+
+	previous := s.inFlight[msg.ID]
+	if previous != nil {
+		previous.cancelled = true
+		previous.stop()
+	}
+	req := &wsRequest{id: msg.ID, ctx: ctx, stop: stop, sourceLang: s.sourceLang, targetLang: s.targetLang, text: msg.Text}
+	s.inFlight[msg.ID] = req
+	s.running++
+	s.mu.Unlock()
+
+	if previous != nil {
+		s.write(WSMessage{Type: "cancelled", ID: previous.id, Text: previous.text})
+	}
+
+	s.wg.Add(1)
+	go s.run(req)
+}
+
+// run translates a request and sends the result unless it was superseded or
+// cancelled in the meantime
+func (s *wsSession) run(req *wsRequest) {
+	defer s.wg.Done()
+	defer req.stop()
+
+	bhashiniClient := services.NewBhashiniClient()
+	cacheRepo := repository.NewTranslationRepository(s.db)
+	translationService := services.NewTranslationService(bhashiniClient, cacheRepo)
+	translationService.SetCaller(s.caller)
+	translatedText, err := translationService.Translate(req.ctx, req.text, req.sourceLang, req.targetLang)
+
+	s.mu.Lock()
+	s.running--
+	cancelled := req.cancelled
+	if s.inFlight[req.id] == req {
+		delete(s.inFlight, req.id)
+	}
+	s.mu.Unlock()
+
+	if cancelled {
+		return
+	}
+	if err != nil {
+		s.write(WSMessage{Type: "error", ID: req.id, Text: req.text, Error: err.Error()})
+		return
+	}
+	s.write(WSMessage{
+		Type:           "translation",
+		ID:             req.id,
+		SourceLang:     req.sourceLang,
+		TargetLang:     req.targetLang,
+		Text:           req.text,
+		TranslatedText: translatedText,
+		Stale:          translationService.StaleSentences() > 0,
+	})
+}
+
+// cancel cancels the in-flight request with an ID
+func (s *wsSession) cancel(id string) {
+	s.mu.Lock()
+	req := s.inFlight[id]
+	if req != nil {
+		req.cancelled = true
+		req.stop()
+		delete(s.inFlight, id)
+	}
+	s.mu.Unlock()
+
+	if req == nil {
+		s.write(WSMessage{Type: "error", ID: id, Error: "no request in flight with this id"})
+		return
+	}
+	s.write(WSMessage{Type: "cancelled", ID: id, Text: req.text})
+}
+
+// cancelAll drops every in-flight request when the client goes away
+func (s *wsSession) cancelAll() {
+	s.mu.Lock()
+	for id, req := range s.inFlight {
+		req.cancelled = true
+		req.stop()
+		delete(s.inFlight, id)
+	}
+	s.mu.Unlock()
+}
+
+// write sends a message; writes from request goroutines are serialised
+func (s *wsSession) write(msg WSMessage) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.WriteJSON(msg)
+}
+
+// validateLanguagePair checks a session's language pair, normalising its
+// codes in place, and returns an error message or an empty string
+func validateLanguagePair(sourceLang, targetLang *string) string {
+	if *sourceLang == "" || *targetLang == "" {
+		return "source_lang and target_lang are required"
+	}
+	return services.UpstreamLanguages().ResolvePair(sourceLang, targetLang)
+}
@@ -0,0 +1,82 @@
+// Package logging sets up structured JSON logging and carries the request ID
+// of the request being served through contexts
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID is the header a request ID is accepted in, returned in and
+// forwarded to Bhashini in
+const HeaderRequestID = "X-Request-ID"
+
+// redacted replaces the value of any attribute that may hold a secret
+const redacted = "[REDACTED]"
+
+// secretKeyParts are the attribute key fragments whose values are never logged
+var secretKeyParts = []string{"authorization", "api_key", "apikey", "secret", "password", "token", "credential"}
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// Setup installs a JSON logger on stdout as the default slog logger, which
+// the standard log package writes through too. LOG_LEVEL sets the lowest
+// level logged: debug, info (default), warn or error.
+func Setup() error {
+	var level slog.Level
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("LOG_LEVEL %q is not valid, use debug, info, warn or error", value)
+		}
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactSecrets,
+	})
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// redactSecrets hides the value of attributes named like credentials, as a
+// last line of defence against logging them by mistake
+func redactSecrets(_ []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	return attr
+}
+
+// WithRequestID returns a copy of ctx carrying a request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext returns the default logger annotated with the request ID and
+// trace of ctx, so every line logged while serving a request can be found
+// from its ID or its trace
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if requestID := RequestID(ctx); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		logger = logger.With("trace_id", spanContext.TraceID().String(), "span_id", spanContext.SpanID().String())
+	}
+	return logger
+}
@@ -0,0 +1,137 @@
+// Package metrics defines the Prometheus metrics exported on /metrics
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "translation"
+
+// Upstream endpoints of the Bhashini API
+const (
+	EndpointSearch  = "search"  // pipeline search
+	EndpointConfig  = "config"  // pipeline config
+	EndpointCompute = "compute" // inference
+)
+
+// Cache tiers
+const (
+	CacheTierPostgres = "postgres"
+)
+
+// Reasons an expired cache entry was served
+const (
+	StaleRevalidate = "revalidate" // within the stale-while-revalidate window
+	StaleError      = "error"      // upstream failed
+)
+
+// StatusNetworkError is the status label of an upstream call that got no
+// response
+const StatusNetworkError = "network_error"
+
+// HTTP server metrics
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to handle HTTP requests, by method, route and status code.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route", "status"})
+
+	BatchItems = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_items",
+		Help:      "Items per batch request, by route.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"route"})
+)
+
+// Upstream Bhashini metrics
+var (
+	UpstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of Bhashini API calls, by endpoint and status code.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30},
+	}, []string{"endpoint", "status"})
+
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Failed Bhashini API calls, by endpoint and status code (network_error when there was no response).",
+	}, []string{"endpoint", "status"})
+
+	UpstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Bhashini API calls retried after a failure, by endpoint.",
+	}, []string{"endpoint"})
+
+	UpstreamInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upstream_in_flight",
+		Help:      "Bhashini API calls in progress, by endpoint.",
+	}, []string{"endpoint"})
+
+	UpstreamBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_batch_size",
+		Help:      "Sentences sent per Bhashini compute call.",
+		Buckets:   []float64{1, 2, 5, 10, 15, 20, 25},
+	})
+)
+
+// Pipeline routing metrics
+var (
+	PipelineHealth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pipeline_health_score",
+		Help:      "Health score of a Bhashini pipeline for a language pair, from 0 to 1, built from its recent success rate and latency.",
+	}, []string{"pipeline", "source_lang", "target_lang"})
+
+	PipelineDemotions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pipeline_demotions_total",
+		Help:      "Times a Bhashini pipeline was demoted for a language pair after failing, by pipeline.",
+	}, []string{"pipeline"})
+
+	PipelineFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pipeline_fallbacks_total",
+		Help:      "Translations handed to the next pipeline of their chain after a pipeline failed, by failed pipeline.",
+	}, []string{"pipeline"})
+)
+
+// Translation cache metrics
+var (
+	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Sentences found in the translation cache, by tier.",
+	}, []string{"tier"})
+
+	CacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Sentences not found in the translation cache, by tier.",
+	}, []string{"tier"})
+
+	CacheErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_errors_total",
+		Help:      "Failed translation cache operations, by tier and operation (lookup or store).",
+	}, []string{"tier", "operation"})
+
+	CacheStale = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_stale_total",
+		Help:      "Sentences served from expired translation cache entries, by tier and reason (revalidate or error).",
+	}, []string{"tier", "reason"})
+)
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"user-service/internal/logging"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessLog logs one line per request with its request ID. Only the path is
+// logged: query strings can carry an API key on WebSocket upgrades, and
+// headers are never logged.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		logging.FromContext(c.UserContext()).LogAttrs(c.UserContext(), level, "request", attrs...)
+		return err
+	}
+}
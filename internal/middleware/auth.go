@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiKeyLocal is the fiber.Ctx locals key holding the authenticated key
+const apiKeyLocal = "apiKey"
+
+// Authenticate rejects requests without a valid API key. The key is read from
+// "Authorization: Bearer <key>" or "X-API-Key", or from the api_key query
+// parameter on WebSocket upgrades, since browsers cannot set headers there.
+//
+// ADMIN_API_KEY, when set, is accepted with every scope. It exists to create
+// the first stored keys and should not be handed to clients.
+func Authenticate(db *sql.DB) fiber.Handler {
+	keyRepo := repository.NewAPIKeyRepository(db)
+	adminKey := strings.TrimSpace(os.Getenv("ADMIN_API_KEY"))
+
+	// Last time each key's last_used_at was written, to keep writes rare
+	var touched sync.Map
+
+	return func(c *fiber.Ctx) error {
+		presented := presentedKey(c)
+		if presented == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status": "error",
+				"error":  "API key required: send Authorization: Bearer <key> or X-API-Key",
+			})
+		}
+
+		if adminKey != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(adminKey)) == 1 {
+			c.Locals(apiKeyLocal, &models.APIKey{
+				Name:   "ADMIN_API_KEY",
+				Scopes: []string{models.ScopeTranslate, models.ScopeManage},
+			})
+			return c.Next()
+		}
+
+		apiKey, err := keyRepo.FindActiveKey(c.UserContext(), services.HashAPIKey(presented))
+		if err != nil {
+			if errors.Is(err, repository.ErrAPIKeyNotFound) {
+				c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"status": "error",
+					"error":  "invalid, revoked or expired API key",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status": "error",
+				"error":  "failed to check API key: " + err.Error(),
+			})
+		}
+
+		if last, ok := touched.Load(apiKey.ID); !ok || time.Since(last.(time.Time)) > time.Minute {
+			touched.Store(apiKey.ID, time.Now())
+			keyRepo.TouchKey(c.UserContext(), apiKey.ID)
+		}
+
+		c.Locals(apiKeyLocal, apiKey)
+		return c.Next()
+	}
+}
+
+// RequireScope rejects requests whose API key does not grant scope. It must
+// run after Authenticate.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiKey := APIKey(c)
+		if apiKey == nil || !apiKey.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status": "error",
+				"error":  "API key does not have the '" + scope + "' scope",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// APIKey returns the key a request was authenticated with, or nil
+func APIKey(c *fiber.Ctx) *models.APIKey {
+	apiKey, _ := c.Locals(apiKeyLocal).(*models.APIKey)
+	return apiKey
+}
+
+// presentedKey extracts the API key sent with a request
+func presentedKey(c *fiber.Ctx) string {
+	if auth := c.Get(fiber.HeaderAuthorization); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	if key := c.Get("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+	if strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") {
+		return c.Query("api_key")
+	}
+	return ""
+}
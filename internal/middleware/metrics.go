@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"user-service/internal/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics records the count and latency of every request by method, route
+// pattern and status code. Routes are labelled by their pattern, such as
+// /v1/jobs/:id, so IDs do not create new series.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		// Route fields are static strings, safe to keep as labels unlike request data
+		route := c.Route()
+		labels := []string{route.Method, route.Path, strconv.Itoa(status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"user-service/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// quotaLocal is the fiber.Ctx locals key holding the key's character quota
+const quotaLocal = "charQuota"
+
+// RateLimit enforces the authenticated key's requests per second, answering
+// 429 with Retry-After once the limit is reached, and makes the key's
+// character quota available to handlers through Caller. It must run after
+// Authenticate.
+func RateLimit(db *sql.DB) fiber.Handler {
+	limiter := services.NewRateLimiter(db)
+
+	return func(c *fiber.Ctx) error {
+		apiKey := APIKey(c)
+
+		if status := limiter.Allow(c.UserContext(), apiKey); status != nil {
+			SetRateLimitHeaders(c, int64(status.Limit), int64(status.Remaining), status.Reset)
+			if !status.Allowed {
+				c.Set(fiber.HeaderRetryAfter, RetryAfterSeconds(time.Until(status.Reset)))
+				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+					"status": "error",
+					"error":  "rate limit of " + strconv.Itoa(status.Limit) + " requests per second exceeded",
+				})
+			}
+		}
+
+		if quota := services.NewCharQuota(db, apiKey); quota != nil {
+			c.Locals(quotaLocal, quota)
+		}
+		return c.Next()
+	}
+}
+
+// Caller returns who a request's translations are done for: its API key and
+// the key's character quota
+func Caller(c *fiber.Ctx) services.Caller {
+	var caller services.Caller
+	if apiKey := APIKey(c); apiKey != nil {
+		caller.APIKeyID = apiKey.ID
+	}
+	caller.Quota, _ = c.Locals(quotaLocal).(*services.CharQuota)
+	return caller
+}
+
+// SetRateLimitHeaders sets the X-RateLimit-* headers. The reset is given in
+// Unix seconds.
+func SetRateLimitHeaders(c *fiber.Ctx, limit, remaining int64, reset time.Time) {
+	c.Set("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+	c.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	c.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+}
+
+// QuotaExceeded writes the 429 response for a translation that would go over
+// the key's character quota
+func QuotaExceeded(c *fiber.Ctx, err *services.QuotaExceededError) error {
+	SetRateLimitHeaders(c, err.Limit, max(err.Limit-err.Used, 0), time.Now().Add(err.RetryAfter))
+	c.Set(fiber.HeaderRetryAfter, RetryAfterSeconds(err.RetryAfter))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"status": "error",
+		"error":  err.Error(),
+	})
+}
+
+// RetryAfterSeconds formats a wait as whole seconds, rounding up
+func RetryAfterSeconds(wait time.Duration) string {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	return strconv.FormatInt(max(seconds, 1), 10)
+}
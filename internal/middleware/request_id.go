@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"strings"
+
+	"user-service/internal/logging"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds the length of a request ID accepted from a client
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID: the client's X-Request-ID when it is a
+// sensible one, or a new UUID. The ID is returned in the response's
+// X-Request-ID header and carried in the request's user context, where the
+// logs and the Bhashini client pick it up.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(logging.HeaderRequestID)
+		if validRequestID(requestID) {
+			// Copied: fiber reuses its buffers once the handler returns
+			requestID = strings.Clone(requestID)
+		} else {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(logging.HeaderRequestID, requestID)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), requestID))
+		return c.Next()
+	}
+}
+
+// validRequestID accepts IDs of letters, digits and the punctuation common in
+// ID formats, so a client cannot inject arbitrary text into logs or headers
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_.:/+=", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"user-service/internal/tracing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for every request, continuing the trace of an
+// incoming W3C traceparent header. The span is stored in the request's user
+// context, c.UserContext(), which handlers pass on to the translation service.
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+		// Request data is copied: fiber reuses its buffers once the handler returns
+		method := strings.Clone(c.Method())
+		ctx, span := tracing.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(strings.Clone(c.Path())),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+			span.RecordError(err)
+		}
+
+		// The route is only known once the request has been routed
+		route := c.Route().Path
+		span.SetName(method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, "")
+		}
+		return err
+	}
+}
+
+// headerCarrier reads and writes trace context in a request's headers
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+// Get returns a copy of a request header
+func (h headerCarrier) Get(key string) string {
+	return strings.Clone(h.c.Get(key))
+}
+
+// Set sets a response header
+func (h headerCarrier) Set(key, value string) {
+	h.c.Set(key, value)
+}
+
+// Keys lists the request's header names
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
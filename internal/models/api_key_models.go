@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// API key scopes
+const (
+	ScopeTranslate = "translate" // translation endpoints and jobs
+	ScopeManage    = "manage"    // cache maintenance and key administration
+)
+
+// Character quota periods
+const (
+	QuotaPeriodDay   = "day"
+	QuotaPeriodMonth = "month"
+)
+
+// APIKey represents a client API key. The key itself is only shown once, when
+// it is created or rotated; afterwards only its prefix is known.
+type APIKey struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	Scopes         []string   `json:"scopes"`
+	CallbackURL    string     `json:"callback_url,omitempty"`
+	CallbackSecret string     `json:"-"`
+	RotatedFrom    string     `json:"rotated_from,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	APIKeyLimits
+}
+
+// APIKeyLimits are a key's request rate and character quota. Nil limits fall
+// back to the service defaults; 0 means unlimited.
+type APIKeyLimits struct {
+	RateLimit            *int    `json:"rate_limit,omitempty"`        // requests per second
+	CharQuota            *int64  `json:"char_quota,omitempty"`        // characters per quota period
+	CharQuotaPeriod      *string `json:"char_quota_period,omitempty"` // day or month
+	QuotaExemptCacheHits bool    `json:"quota_exempt_cache_hits"`     // only charge characters sent upstream
+}
+
+// HasScope reports whether the key grants a scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -22,6 +22,7 @@ type PipelineRequestConfig struct {
 type PipelineConfigResponse struct {
 	PipelineInferenceAPIEndPoint PipelineInferenceAPIEndPoint `json:"pipelineInferenceAPIEndPoint"`
 	PipelineResponseConfig       []PipelineResponseConfigItem `json:"pipelineResponseConfig"`
+	PipelineID                   string                       `json:"-"` // the pipeline the config was requested for
 }
 
 // PipelineInferenceAPIEndPoint contains the endpoint and auth details
@@ -77,8 +78,10 @@ type PipelineComputeTaskConfig struct {
 
 // TaskLanguage represents language configuration for a task
 type TaskLanguage struct {
-	SourceLanguage string `json:"sourceLanguage,omitempty"`
-	TargetLanguage string `json:"targetLanguage,omitempty"`
+	SourceLanguage   string `json:"sourceLanguage,omitempty"`
+	SourceScriptCode string `json:"sourceScriptCode,omitempty"`
+	TargetLanguage   string `json:"targetLanguage,omitempty"`
+	TargetScriptCode string `json:"targetScriptCode,omitempty"`
 }
 
 // InputData represents input data for the pipeline
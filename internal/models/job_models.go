@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Translation job types
+const (
+	JobTypeBatch    = "batch"
+	JobTypeDocument = "document"
+)
+
+// Translation job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Document formats accepted by document jobs
+const (
+	DocumentFormatText      = "text"
+	DocumentFormatMarkdown  = "markdown"
+	DocumentFormatSubtitles = "subtitles"
+)
+
+// TranslationJob represents an asynchronous translation job
+type TranslationJob struct {
+	ID             string     `json:"id"`
+	Type           string     `json:"type"`
+	DocumentFormat string     `json:"document_format,omitempty"`
+	Status         string     `json:"status"`
+	TotalItems     int        `json:"total_items"`
+	CompletedItems int        `json:"completed_items"`
+	Attempts       int        `json:"attempts"`
+	Error          string     `json:"error,omitempty"`
+	CallbackURL    string     `json:"callback_url,omitempty"`
+	APIKeyID       string     `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// TranslationJobItem represents one text of a translation job
+type TranslationJobItem struct {
+	Index          int    `json:"index"`
+	SourceText     string `json:"source_text"`
+	SourceLang     string `json:"source_lang"`
+	TargetLang     string `json:"target_lang"`
+	TranslatedText string `json:"translated_text"`
+	Completed      bool   `json:"completed"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// CachedTranslation is a translation found in the cache. It may have
+// expired: expired entries are kept for a while to fall back on.
+type CachedTranslation struct {
+	TranslatedText string
+	ExpiresAt      time.Time
+}
+
+// Stale reports whether the entry has expired
+func (t *CachedTranslation) Stale() bool {
+	return !time.Now().Before(t.ExpiresAt)
+}
@@ -0,0 +1,96 @@
+package models
+
+import "time"
+
+// UsageCounts are the counters kept for every usage rollup
+type UsageCounts struct {
+	Requests           int64 `json:"requests"`
+	Errors             int64 `json:"errors"`
+	Sentences          int64 `json:"sentences"`
+	CacheHits          int64 `json:"cache_hits"`
+	Characters         int64 `json:"characters"`
+	UpstreamCharacters int64 `json:"upstream_characters"`
+	UpstreamCalls      int64 `json:"upstream_calls"`
+	LatencyMS          int64 `json:"latency_ms"`
+	UpstreamLatencyMS  int64 `json:"upstream_latency_ms"`
+}
+
+// Add adds other's counters to c
+func (c *UsageCounts) Add(other UsageCounts) {
+	c.Requests += other.Requests
+	c.Errors += other.Errors
+	c.Sentences += other.Sentences
+	c.CacheHits += other.CacheHits
+	c.Characters += other.Characters
+	c.UpstreamCharacters += other.UpstreamCharacters
+	c.UpstreamCalls += other.UpstreamCalls
+	c.LatencyMS += other.LatencyMS
+	c.UpstreamLatencyMS += other.UpstreamLatencyMS
+}
+
+// UsageRollup is one row of the hourly usage rollups
+type UsageRollup struct {
+	BucketStart time.Time
+	APIKeyID    string
+	SourceLang  string
+	TargetLang  string
+	PipelineID  string
+	UsageCounts
+}
+
+// UsageFilter selects and groups usage for a report
+type UsageFilter struct {
+	APIKeyID   string
+	SourceLang string
+	TargetLang string
+	From       time.Time
+	To         time.Time
+	GroupBy    []string // client, pair, pipeline, and at most one of hour, day, month
+}
+
+// UsageReportRow is the usage of one group. Only the fields of the report's
+// grouping are set.
+type UsageReportRow struct {
+	PeriodStart  *time.Time `json:"period_start,omitempty"`
+	APIKeyID     string     `json:"api_key_id,omitempty"`
+	APIKeyName   string     `json:"api_key_name,omitempty"`
+	APIKeyPrefix string     `json:"api_key_prefix,omitempty"`
+	SourceLang   string     `json:"source_lang,omitempty"`
+	TargetLang   string     `json:"target_lang,omitempty"`
+	PipelineID   string     `json:"pipeline_id,omitempty"`
+	UsageCounts
+	UsageRatios
+}
+
+// UsageRatios are derived from a row's counters
+type UsageRatios struct {
+	CacheHitRatio        float64 `json:"cache_hit_ratio"`         // sentences served from the cache
+	CharactersSaved      int64   `json:"characters_saved"`        // characters not sent upstream
+	AvgLatencyMS         float64 `json:"avg_latency_ms"`          // per request
+	AvgUpstreamLatencyMS float64 `json:"avg_upstream_latency_ms"` // per upstream call
+}
+
+// UsageReport is the response of the usage endpoint
+type UsageReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	GroupBy []string         `json:"group_by"`
+	Rows    []UsageReportRow `json:"rows"`
+	Totals  UsageReportRow   `json:"totals"`
+}
+
+// Ratios computes the derived figures of a set of counters
+func (c UsageCounts) Ratios() UsageRatios {
+	var ratios UsageRatios
+	if c.Sentences > 0 {
+		ratios.CacheHitRatio = float64(c.CacheHits) / float64(c.Sentences)
+	}
+	ratios.CharactersSaved = c.Characters - c.UpstreamCharacters
+	if c.Requests > 0 {
+		ratios.AvgLatencyMS = float64(c.LatencyMS) / float64(c.Requests)
+	}
+	if c.UpstreamCalls > 0 {
+		ratios.AvgUpstreamLatencyMS = float64(c.UpstreamLatencyMS) / float64(c.UpstreamCalls)
+	}
+	return ratios
+}
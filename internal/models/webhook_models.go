@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Webhook event types
+const (
+	WebhookEventJobCompleted = "job.completed"
+	WebhookEventJobFailed    = "job.failed"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookStatusPending   = "pending"
+	WebhookStatusDelivered = "delivered"
+	WebhookStatusFailed    = "failed"
+)
+
+// WebhookEvent is the JSON body posted to a callback URL
+type WebhookEvent struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      TranslationJob `json:"data"`
+}
+
+// WebhookDelivery represents one event queued for a callback URL
+type WebhookDelivery struct {
+	ID          string                   `json:"id"`
+	JobID       string                   `json:"job_id"`
+	EventType   string                   `json:"event_type"`
+	URL         string                   `json:"url"`
+	Payload     string                   `json:"-"`
+	Secret      string                   `json:"-"`
+	Status      string                   `json:"status"`
+	Attempts    int                      `json:"attempts"`
+	NextAttempt *time.Time               `json:"next_attempt_at,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	DeliveredAt *time.Time               `json:"delivered_at,omitempty"`
+	Log         []WebhookDeliveryAttempt `json:"log"`
+}
+
+// WebhookDeliveryAttempt is one entry of the delivery log
+type WebhookDeliveryAttempt struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int       `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
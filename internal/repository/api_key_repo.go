@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// ErrAPIKeyNotFound is returned when a key does not exist, or is revoked or
+// expired where an active key is required
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// APIKeyRepository handles API key storage
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// apiKeyColumns lists the columns scanned by scanAPIKey, in order
+const apiKeyColumns = `id, name, key_prefix, scopes, COALESCE(callback_url, ''), COALESCE(callback_secret, ''),
+	COALESCE(rotated_from::text, ''), created_at, last_used_at, expires_at, revoked_at,
+	rate_limit, char_quota, char_quota_period, quota_exempt_cache_hits`
+
+// activeAPIKey is the condition for a key that may be used
+const activeAPIKey = `revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+	var rateLimit sql.NullInt32
+	var charQuota sql.NullInt64
+	var charQuotaPeriod sql.NullString
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CallbackURL, &key.CallbackSecret,
+		&key.RotatedFrom, &key.CreatedAt, &lastUsedAt, &expiresAt, &revokedAt,
+		&rateLimit, &charQuota, &charQuotaPeriod, &key.QuotaExemptCacheHits)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	if rateLimit.Valid {
+		limit := int(rateLimit.Int32)
+		key.RateLimit = &limit
+	}
+	if charQuota.Valid {
+		key.CharQuota = &charQuota.Int64
+	}
+	if charQuotaPeriod.Valid {
+		key.CharQuotaPeriod = &charQuotaPeriod.String
+	}
+	return &key, nil
+}
+
+// CreateKey stores a new key under its hash
+func (r *APIKeyRepository) CreateKey(ctx context.Context, key models.APIKey, keyHash string) (*models.APIKey, error) {
+	query := `
+		INSERT INTO api_keys (name, key_prefix, key_hash, scopes, callback_url, callback_secret, expires_at,
+			rate_limit, char_quota, char_quota_period, quota_exempt_cache_hits)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10, $11)
+		RETURNING ` + apiKeyColumns
+
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes),
+		key.CallbackURL, key.CallbackSecret, key.ExpiresAt,
+		key.RateLimit, key.CharQuota, key.CharQuotaPeriod, key.QuotaExemptCacheHits))
+}
+
+// GetKey looks up a key by ID, whatever its state
+func (r *APIKeyRepository) GetKey(ctx context.Context, id string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, id))
+}
+
+// FindActiveKey looks up a usable key by its hash
+func (r *APIKeyRepository) FindActiveKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND ` + activeAPIKey
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash))
+}
+
+// TouchKey records that a key was used. To keep writes off the hot path the
+// timestamp is only moved once a minute.
+func (r *APIKeyRepository) TouchKey(ctx context.Context, id string) error {
+	query := `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// ListKeys returns every key, newest first
+func (r *APIKeyRepository) ListKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey revokes an active key immediately
+func (r *APIKeyRepository) RevokeKey(ctx context.Context, id string) (*models.APIKey, error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND ` + activeAPIKey + ` RETURNING ` + apiKeyColumns
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, id))
+}
+
+// SetLimits replaces the rate limit and character quota of an active key
+func (r *APIKeyRepository) SetLimits(ctx context.Context, id string, limits models.APIKeyLimits) (*models.APIKey, error) {
+	query := `
+		UPDATE api_keys SET rate_limit = $2, char_quota = $3, char_quota_period = $4, quota_exempt_cache_hits = $5
+		WHERE id = $1 AND ` + activeAPIKey + ` RETURNING ` + apiKeyColumns
+
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, id, limits.RateLimit, limits.CharQuota, limits.CharQuotaPeriod, limits.QuotaExemptCacheHits))
+}
+
+// RotateKey replaces an active key with a new one that has the same name,
+// scopes, callback, limits and expiry. The old key stops working after grace, or
+// straight away when grace is zero, so clients can switch over without
+// downtime.
+func (r *APIKeyRepository) RotateKey(ctx context.Context, id, prefix, keyHash string, grace time.Duration) (*models.APIKey, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	old, err := scanAPIKey(tx.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 AND `+activeAPIKey+` FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO api_keys (name, key_prefix, key_hash, scopes, callback_url, callback_secret, expires_at, rotated_from,
+			rate_limit, char_quota, char_quota_period, quota_exempt_cache_hits)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10, $11, $12)
+		RETURNING ` + apiKeyColumns
+	rotated, err := scanAPIKey(tx.QueryRowContext(ctx, query, old.Name, prefix, keyHash, pq.Array(old.Scopes),
+		old.CallbackURL, old.CallbackSecret, old.ExpiresAt, old.ID,
+		old.RateLimit, old.CharQuota, old.CharQuotaPeriod, old.QuotaExemptCacheHits))
+	if err != nil {
+		return nil, err
+	}
+
+	if grace > 0 {
+		_, err = tx.ExecContext(ctx, `UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, $2), $2) WHERE id = $1`, id, time.Now().Add(grace))
+	} else {
+		_, err = tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1`, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rotated, nil
+}
@@ -0,0 +1,344 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrJobNotFound is returned when a job does not exist or has expired
+var ErrJobNotFound = errors.New("job not found")
+
+// JobRepository handles translation job queue operations
+type JobRepository struct {
+	db *sql.DB
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// jobColumns lists the columns scanned by scanJob, in order
+const jobColumns = `id, job_type, COALESCE(document_format, ''), status, total_items, completed_items,
+	attempts, COALESCE(error, ''), COALESCE(callback_url, ''), COALESCE(api_key_id::text, ''), created_at, started_at, finished_at, expires_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJob scans a row selected with jobColumns
+func scanJob(row rowScanner) (*models.TranslationJob, error) {
+	var job models.TranslationJob
+	var startedAt, finishedAt, expiresAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Type, &job.DocumentFormat, &job.Status, &job.TotalItems, &job.CompletedItems,
+		&job.Attempts, &job.Error, &job.CallbackURL, &job.APIKeyID, &job.CreatedAt, &startedAt, &finishedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if expiresAt.Valid {
+		job.ExpiresAt = &expiresAt.Time
+	}
+	return &job, nil
+}
+
+// CreateJob stores a queued job and its items in one transaction. The job's
+// type, document format, callback URL and API key are taken from job; an
+// empty callback URL means no webhook is sent when the job finishes.
+func (r *JobRepository) CreateJob(ctx context.Context, job models.TranslationJob, callbackSecret string, items []models.TranslationJobItem) (*models.TranslationJob, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id := uuid.New().String()
+	query := `
+		INSERT INTO translation_jobs (id, job_type, document_format, status, total_items, callback_url, callback_secret, api_key_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, '')::uuid)
+		RETURNING ` + jobColumns
+
+	created, err := scanJob(tx.QueryRowContext(ctx, query, id, job.Type, job.DocumentFormat, models.JobStatusQueued, len(items),
+		job.CallbackURL, callbackSecret, job.APIKeyID))
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO translation_job_items (job_id, item_index, source_text, source_lang, target_lang)
+		VALUES ($1, $2, $3, $4, $5)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for i, item := range items {
+		if _, err := stmt.ExecContext(ctx, id, i, item.SourceText, item.SourceLang, item.TargetLang); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetJob retrieves a job that has not expired
+func (r *JobRepository) GetJob(ctx context.Context, id string) (*models.TranslationJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM translation_jobs WHERE id = $1 AND (expires_at IS NULL OR expires_at > NOW())`
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// ClaimJob hands the oldest runnable job to a worker. Queued jobs are claimed
+// once their run_after has passed; running jobs whose worker stopped sending
+// heartbeats for lockTimeout (for example after a restart) are claimed again
+// and resume from their first unfinished item. SKIP LOCKED lets several
+// workers and replicas poll the same table without blocking each other.
+func (r *JobRepository) ClaimJob(ctx context.Context, workerID string, lockTimeout time.Duration) (*models.TranslationJob, error) {
+	query := `
+		UPDATE translation_jobs
+		SET status = $1, locked_by = $2, locked_at = NOW(),
+			started_at = COALESCE(started_at, NOW()), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM translation_jobs
+			WHERE (status = $3 AND run_after <= NOW())
+			OR (status = $1 AND locked_at < NOW() - make_interval(secs => $4))
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, models.JobStatusRunning, workerID, models.JobStatusQueued, lockTimeout.Seconds()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// PendingItems returns up to limit unfinished items of a job, in order
+func (r *JobRepository) PendingItems(ctx context.Context, jobID string, limit int) ([]models.TranslationJobItem, error) {
+	query := `
+		SELECT item_index, source_text, source_lang, target_lang
+		FROM translation_job_items
+		WHERE job_id = $1 AND completed_at IS NULL
+		ORDER BY item_index
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.TranslationJobItem
+	for rows.Next() {
+		var item models.TranslationJobItem
+		if err := rows.Scan(&item.Index, &item.SourceText, &item.SourceLang, &item.TargetLang); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// CompleteItems saves translated items, bumps the job's progress and renews
+// the worker's lock. It reports false when the job no longer belongs to the
+// worker, because it was cancelled or claimed by someone else.
+func (r *JobRepository) CompleteItems(ctx context.Context, jobID, workerID string, items []models.TranslationJobItem) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE translation_jobs
+		SET completed_items = completed_items + $3, locked_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = $4
+	`, jobID, workerID, len(items), models.JobStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		UPDATE translation_job_items
+		SET translated_text = $3, completed_at = NOW()
+		WHERE job_id = $1 AND item_index = $2
+	`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		if _, err := stmt.ExecContext(ctx, jobID, item.Index, item.TranslatedText); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Heartbeat renews a worker's lock on a running job. It reports false when the
+// job no longer belongs to the worker.
+func (r *JobRepository) Heartbeat(ctx context.Context, jobID, workerID string) (bool, error) {
+	query := `UPDATE translation_jobs SET locked_at = NOW() WHERE id = $1 AND locked_by = $2 AND status = $3`
+	result, err := r.db.ExecContext(ctx, query, jobID, workerID, models.JobStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FinishJob marks a job completed or failed and starts its retention period.
+// webhook builds the delivery of the finished job's event, or returns nil for
+// none; it is queued in the same transaction, so the job never finishes
+// without it. FinishJob returns the finished job, or nil when the job no
+// longer belongs to the worker.
+func (r *JobRepository) FinishJob(ctx context.Context, jobID, workerID, status, errorMessage string, retention time.Duration,
+	webhook func(*models.TranslationJob) (*models.WebhookDelivery, error)) (*models.TranslationJob, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE translation_jobs
+		SET status = $3, error = NULLIF($4, ''), finished_at = NOW(), expires_at = $5,
+			locked_by = NULL, locked_at = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = $6
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(tx.QueryRowContext(ctx, query, jobID, workerID, status, errorMessage, time.Now().Add(retention), models.JobStatusRunning))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	delivery, err := webhook(job)
+	if err != nil {
+		return nil, err
+	}
+	if delivery != nil {
+		if err := insertDelivery(ctx, tx, *delivery); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// RetryJob puts a job back in the queue to be picked up again after delay.
+// Unless countAttempt is set, the attempt that just ran is not counted
+// towards the job's attempts.
+func (r *JobRepository) RetryJob(ctx context.Context, jobID, workerID, errorMessage string, delay time.Duration, countAttempt bool) error {
+	query := `
+		UPDATE translation_jobs
+		SET status = $3, error = $4, run_after = $5, locked_by = NULL, locked_at = NULL,
+			attempts = CASE WHEN $7 THEN attempts ELSE GREATEST(attempts - 1, 0) END
+		WHERE id = $1 AND locked_by = $2 AND status = $6
+	`
+	_, err := r.db.ExecContext(ctx, query, jobID, workerID, models.JobStatusQueued, errorMessage, time.Now().Add(delay), models.JobStatusRunning, countAttempt)
+	return err
+}
+
+// ReleaseJob hands a running job back to the queue without counting the
+// attempt, so another worker can claim it straight away
+func (r *JobRepository) ReleaseJob(ctx context.Context, jobID, workerID string) error {
+	query := `
+		UPDATE translation_jobs
+		SET status = $3, attempts = GREATEST(attempts - 1, 0), run_after = NOW(), locked_by = NULL, locked_at = NULL
+		WHERE id = $1 AND locked_by = $2 AND status = $4
+	`
+	_, err := r.db.ExecContext(ctx, query, jobID, workerID, models.JobStatusQueued, models.JobStatusRunning)
+	return err
+}
+
+// CancelJob cancels a job that has not finished yet. It reports false when the
+// job had already finished.
+func (r *JobRepository) CancelJob(ctx context.Context, jobID string, retention time.Duration) (bool, error) {
+	query := `
+		UPDATE translation_jobs
+		SET status = $2, finished_at = NOW(), expires_at = $3, locked_by = NULL, locked_at = NULL
+		WHERE id = $1 AND status IN ($4, $5)
+	`
+	result, err := r.db.ExecContext(ctx, query, jobID, models.JobStatusCancelled, time.Now().Add(retention), models.JobStatusQueued, models.JobStatusRunning)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetItems returns every item of a job, in order
+func (r *JobRepository) GetItems(ctx context.Context, jobID string) ([]models.TranslationJobItem, error) {
+	query := `
+		SELECT item_index, source_text, source_lang, target_lang, COALESCE(translated_text, ''), completed_at IS NOT NULL
+		FROM translation_job_items
+		WHERE job_id = $1
+		ORDER BY item_index
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.TranslationJobItem
+	for rows.Next() {
+		var item models.TranslationJobItem
+		if err := rows.Scan(&item.Index, &item.SourceText, &item.SourceLang, &item.TargetLang, &item.TranslatedText, &item.Completed); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// DeleteExpiredJobs removes finished jobs whose retention period has passed
+func (r *JobRepository) DeleteExpiredJobs(ctx context.Context) error {
+	query := `DELETE FROM translation_jobs WHERE expires_at < NOW()`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
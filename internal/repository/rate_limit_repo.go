@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RateLimitRepository keeps the per-key request and character counters in
+// Postgres, so limits hold across replicas
+type RateLimitRepository struct {
+	db *sql.DB
+}
+
+// NewRateLimitRepository creates a new rate limit repository
+func NewRateLimitRepository(db *sql.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// CountRequest counts a request against the key's current one-second window
+// and returns the number of requests in that window so far
+func (r *RateLimitRepository) CountRequest(ctx context.Context, apiKeyID string) (int, error) {
+	query := `
+		INSERT INTO api_key_request_windows AS w (api_key_id, window_start, requests)
+		VALUES ($1, date_trunc('second', NOW()), 1)
+		ON CONFLICT (api_key_id) DO UPDATE SET
+			requests = CASE WHEN w.window_start = EXCLUDED.window_start THEN w.requests + 1 ELSE 1 END,
+			window_start = EXCLUDED.window_start
+		RETURNING requests
+	`
+
+	var requests int
+	err := r.db.QueryRowContext(ctx, query, apiKeyID).Scan(&requests)
+	return requests, err
+}
+
+// ReserveCharacters charges characters to the key's usage for the current day
+// or month, unless that would take it over limit. It returns whether the
+// characters were charged, the usage afterwards (or as it stands, when they
+// were not), and how long until the period resets.
+func (r *RateLimitRepository) ReserveCharacters(ctx context.Context, apiKeyID, period string, characters, limit int64) (bool, int64, time.Duration, error) {
+	query := `
+		INSERT INTO api_key_character_usage AS u (api_key_id, period, period_start, characters)
+		SELECT $1::uuid, $2::text, date_trunc($2::text, NOW()), $3::bigint
+		WHERE $3::bigint <= $4::bigint
+		ON CONFLICT (api_key_id, period, period_start) DO UPDATE SET
+			characters = u.characters + EXCLUDED.characters
+		WHERE u.characters + EXCLUDED.characters <= $4::bigint
+		RETURNING characters, EXTRACT(EPOCH FROM period_start + ('1 ' || period)::interval - NOW())
+	`
+
+	var used int64
+	var resetSeconds float64
+	err := r.db.QueryRowContext(ctx, query, apiKeyID, period, characters, limit).Scan(&used, &resetSeconds)
+	if err == sql.ErrNoRows {
+		// Over the limit; report the usage as it stands
+		query = `
+			SELECT COALESCE(SUM(characters), 0),
+				EXTRACT(EPOCH FROM date_trunc($2::text, NOW()) + ('1 ' || $2::text)::interval - NOW())
+			FROM api_key_character_usage
+			WHERE api_key_id = $1 AND period = $2 AND period_start = date_trunc($2::text, NOW())
+		`
+		err = r.db.QueryRowContext(ctx, query, apiKeyID, period).Scan(&used, &resetSeconds)
+		if err != nil {
+			return false, 0, 0, err
+		}
+		return false, used, time.Duration(resetSeconds * float64(time.Second)), nil
+	}
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return true, used, time.Duration(resetSeconds * float64(time.Second)), nil
+}
+
+// ReleaseCharacters gives back characters that were charged but never sent
+// upstream
+func (r *RateLimitRepository) ReleaseCharacters(ctx context.Context, apiKeyID, period string, characters int64) error {
+	query := `
+		UPDATE api_key_character_usage SET characters = GREATEST(characters - $3, 0)
+		WHERE api_key_id = $1 AND period = $2 AND period_start = date_trunc($2::text, NOW())
+	`
+	_, err := r.db.ExecContext(ctx, query, apiKeyID, period, characters)
+	return err
+}
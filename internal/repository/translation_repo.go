@@ -1,10 +1,16 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
+	"user-service/internal/models"
+	"user-service/internal/tracing"
+
 	"github.com/google/uuid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TranslationRepository handles translation cache operations
@@ -17,10 +23,22 @@ func NewTranslationRepository(db *sql.DB) *TranslationRepository {
 	return &TranslationRepository{db: db}
 }
 
-// GetCachedTranslation retrieves a cached translation if it exists and hasn't expired
-func (r *TranslationRepository) GetCachedTranslation(sourceText, sourceLang, targetLang string) (string, bool, error) {
-	var translatedText string
-	var expiresAt time.Time
+// GetCachedTranslation retrieves a cached translation that is fresh or
+// expired less than maxStale ago, or nil if there is none
+func (r *TranslationRepository) GetCachedTranslation(ctx context.Context, sourceText, sourceLang, targetLang string, maxStale time.Duration) (cached *models.CachedTranslation, err error) {
+	ctx, span := startCacheSpan(ctx, "TranslationRepository.GetCachedTranslation", "SELECT", sourceLang, targetLang)
+	defer func() {
+		if err == nil {
+			cacheResult := tracing.CacheMiss
+			if cached != nil && cached.Stale() {
+				cacheResult = tracing.CacheStale
+			} else if cached != nil {
+				cacheResult = tracing.CacheHit
+			}
+			span.SetAttributes(tracing.AttrCacheResult.String(cacheResult))
+		}
+		tracing.End(span, err)
+	}()
 
 	query := `
 		SELECT translated_text, expires_at 
@@ -28,24 +46,28 @@ func (r *TranslationRepository) GetCachedTranslation(sourceText, sourceLang, tar
 		WHERE source_text = $1 
 		AND source_lang = $2 
 		AND target_lang = $3 
-		AND expires_at > NOW()
+		AND expires_at > $4
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
 
-	err := r.db.QueryRow(query, sourceText, sourceLang, targetLang).Scan(&translatedText, &expiresAt)
+	cached = &models.CachedTranslation{}
+	err = r.db.QueryRowContext(ctx, query, sourceText, sourceLang, targetLang, time.Now().Add(-maxStale)).Scan(&cached.TranslatedText, &cached.ExpiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return "", false, nil
+			return nil, nil
 		}
-		return "", false, err
+		return nil, err
 	}
 
-	return translatedText, true, nil
+	return cached, nil
 }
 
 // CacheTranslation stores a translation in the cache
-func (r *TranslationRepository) CacheTranslation(sourceText, sourceLang, targetLang, translatedText string, ttl time.Duration) error {
+func (r *TranslationRepository) CacheTranslation(ctx context.Context, sourceText, sourceLang, targetLang, translatedText string, ttl time.Duration) (err error) {
+	ctx, span := startCacheSpan(ctx, "TranslationRepository.CacheTranslation", "INSERT", sourceLang, targetLang)
+	defer func() { tracing.End(span, err) }()
+
 	id := uuid.New().String()
 	expiresAt := time.Now().Add(ttl)
 
@@ -59,13 +81,23 @@ func (r *TranslationRepository) CacheTranslation(sourceText, sourceLang, targetL
 			expires_at = EXCLUDED.expires_at
 	`
 
-	_, err := r.db.Exec(query, id, sourceText, sourceLang, targetLang, translatedText, expiresAt)
+	_, err = r.db.ExecContext(ctx, query, id, sourceText, sourceLang, targetLang, translatedText, expiresAt)
 	return err
 }
 
-// CleanExpiredTranslations removes expired cache entries
-func (r *TranslationRepository) CleanExpiredTranslations() error {
-	query := `DELETE FROM translation_cache WHERE expires_at < NOW()`
-	_, err := r.db.Exec(query)
+// CleanExpiredTranslations removes cache entries that expired more than
+// keepStale ago
+func (r *TranslationRepository) CleanExpiredTranslations(ctx context.Context, keepStale time.Duration) error {
+	query := `DELETE FROM translation_cache WHERE expires_at < $1`
+	_, err := r.db.ExecContext(ctx, query, time.Now().Add(-keepStale))
 	return err
 }
+
+// startCacheSpan starts the client span of a translation cache query
+func startCacheSpan(ctx context.Context, name, operation, sourceLang, targetLang string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation), semconv.DBCollectionName("translation_cache")),
+		trace.WithAttributes(tracing.Pair(sourceLang, targetLang)...),
+	)
+}
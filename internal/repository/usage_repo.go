@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+)
+
+// NilAPIKeyID stands for the admin key and work no key is known for in the
+// usage rollups
+const NilAPIKeyID = "00000000-0000-0000-0000-000000000000"
+
+// UsageRepository handles the usage rollups
+type UsageRepository struct {
+	db *sql.DB
+}
+
+// NewUsageRepository creates a new usage repository
+func NewUsageRepository(db *sql.DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// AddRollups adds counters to their hourly rollups in one transaction
+func (r *UsageRepository) AddRollups(ctx context.Context, rollups []models.UsageRollup) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO usage_rollups AS u (bucket_start, api_key_id, source_lang, target_lang, pipeline_id,
+			requests, errors, sentences, cache_hits, characters, upstream_characters, upstream_calls,
+			latency_ms, upstream_latency_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (bucket_start, api_key_id, source_lang, target_lang, pipeline_id) DO UPDATE SET
+			requests = u.requests + EXCLUDED.requests,
+			errors = u.errors + EXCLUDED.errors,
+			sentences = u.sentences + EXCLUDED.sentences,
+			cache_hits = u.cache_hits + EXCLUDED.cache_hits,
+			characters = u.characters + EXCLUDED.characters,
+			upstream_characters = u.upstream_characters + EXCLUDED.upstream_characters,
+			upstream_calls = u.upstream_calls + EXCLUDED.upstream_calls,
+			latency_ms = u.latency_ms + EXCLUDED.latency_ms,
+			upstream_latency_ms = u.upstream_latency_ms + EXCLUDED.upstream_latency_ms
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rollup := range rollups {
+		apiKeyID := rollup.APIKeyID
+		if apiKeyID == "" {
+			apiKeyID = NilAPIKeyID
+		}
+		_, err := stmt.ExecContext(ctx, rollup.BucketStart, apiKeyID, rollup.SourceLang, rollup.TargetLang, rollup.PipelineID,
+			rollup.Requests, rollup.Errors, rollup.Sentences, rollup.CacheHits, rollup.Characters,
+			rollup.UpstreamCharacters, rollup.UpstreamCalls, rollup.LatencyMS, rollup.UpstreamLatencyMS)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Report sums the rollups matching a filter per group. Groups are ordered by
+// period, then by characters, largest first.
+func (r *UsageRepository) Report(ctx context.Context, filter models.UsageFilter) ([]models.UsageReportRow, error) {
+	var columns, groups []string
+	var period string
+	periodColumn := 0
+	for _, group := range filter.GroupBy {
+		switch group {
+		case "hour", "day", "month":
+			period = group
+			periodColumn = len(columns) + 1
+			columns = append(columns, fmt.Sprintf("date_trunc('%s', u.bucket_start)", group))
+		case "client":
+			columns = append(columns, "u.api_key_id::text", "COALESCE(k.name, '')", "COALESCE(k.key_prefix, '')")
+		case "pair":
+			columns = append(columns, "u.source_lang", "u.target_lang")
+		case "pipeline":
+			columns = append(columns, "COALESCE(NULLIF(u.pipeline_id, ''), 'cache')")
+		default:
+			return nil, fmt.Errorf("unknown usage grouping %q", group)
+		}
+	}
+	for i := range columns {
+		groups = append(groups, fmt.Sprint(i+1))
+	}
+
+	conditions := []string{"u.bucket_start >= $1", "u.bucket_start < $2"}
+	args := []interface{}{filter.From, filter.To}
+	if filter.APIKeyID != "" {
+		args = append(args, filter.APIKeyID)
+		conditions = append(conditions, fmt.Sprintf("u.api_key_id = $%d", len(args)))
+	}
+	if filter.SourceLang != "" {
+		args = append(args, filter.SourceLang)
+		conditions = append(conditions, fmt.Sprintf("u.source_lang = $%d", len(args)))
+	}
+	if filter.TargetLang != "" {
+		args = append(args, filter.TargetLang)
+		conditions = append(conditions, fmt.Sprintf("u.target_lang = $%d", len(args)))
+	}
+
+	sums := `SUM(u.requests), SUM(u.errors), SUM(u.sentences), SUM(u.cache_hits), SUM(u.characters),
+		SUM(u.upstream_characters), SUM(u.upstream_calls), SUM(u.latency_ms), SUM(u.upstream_latency_ms)`
+	query := `SELECT ` + strings.Join(append(columns, sums), ", ") + `
+		FROM usage_rollups u LEFT JOIN api_keys k ON k.id = u.api_key_id
+		WHERE ` + strings.Join(conditions, " AND ")
+	order := "SUM(u.characters) DESC"
+	if len(groups) > 0 {
+		query += ` GROUP BY ` + strings.Join(groups, ", ")
+		if period != "" {
+			order = fmt.Sprintf("%d, %s", periodColumn, order)
+		}
+		query += ` ORDER BY ` + order
+	} else {
+		// Without groups SUM returns NULLs when nothing matches
+		query += ` HAVING COUNT(*) > 0`
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []models.UsageReportRow{}
+	for rows.Next() {
+		var row models.UsageReportRow
+		var periodStart time.Time
+		var dest []interface{}
+		for _, group := range filter.GroupBy {
+			switch group {
+			case "hour", "day", "month":
+				dest = append(dest, &periodStart)
+			case "client":
+				dest = append(dest, &row.APIKeyID, &row.APIKeyName, &row.APIKeyPrefix)
+			case "pair":
+				dest = append(dest, &row.SourceLang, &row.TargetLang)
+			case "pipeline":
+				dest = append(dest, &row.PipelineID)
+			}
+		}
+		dest = append(dest, &row.Requests, &row.Errors, &row.Sentences, &row.CacheHits, &row.Characters,
+			&row.UpstreamCharacters, &row.UpstreamCalls, &row.LatencyMS, &row.UpstreamLatencyMS)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if period != "" {
+			row.PeriodStart = &periodStart
+		}
+		row.UsageRatios = row.UsageCounts.Ratios()
+		report = append(report, row)
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"user-service/internal/models"
+)
+
+// WebhookRepository handles webhook deliveries and their delivery log
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// insertDelivery queues an event for delivery to a job's callback URL, in the
+// transaction that finishes the job. The delivery ID doubles as the event ID,
+// so receivers can drop redeliveries.
+func insertDelivery(ctx context.Context, tx *sql.Tx, delivery models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, job_id, event_type, url, payload, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := tx.ExecContext(ctx, query, delivery.ID, delivery.JobID, delivery.EventType, delivery.URL, delivery.Payload, models.WebhookStatusPending)
+	return err
+}
+
+// ClaimDueDeliveries takes up to limit pending deliveries whose next attempt
+// is due. Claimed deliveries have their attempt counted and are pushed back by
+// lease, so other dispatchers skip them while they are being sent and a
+// dispatcher that dies mid-send only delays them.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $3)
+		FROM translation_jobs j
+		WHERE j.id = d.job_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT $2
+		)
+		RETURNING d.id, d.job_id, d.event_type, d.url, d.payload, COALESCE(j.callback_secret, ''),
+			d.status, d.attempts, d.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, models.WebhookStatusPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.JobID, &d.EventType, &d.URL, &d.Payload, &d.Secret, &d.Status, &d.Attempts, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordAttempt appends an attempt to the delivery log and moves the delivery
+// to status. Pending deliveries are retried at nextAttempt.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, deliveryID string, attempt models.WebhookDeliveryAttempt, status string, nextAttempt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), $5)
+		ON CONFLICT (delivery_id, attempt) DO NOTHING
+	`, deliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMs)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, next_attempt_at = $3,
+			delivered_at = CASE WHEN $2 = $4 THEN NOW() ELSE delivered_at END
+		WHERE id = $1
+	`, deliveryID, status, nextAttempt, models.WebhookStatusDelivered)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListDeliveries returns a job's deliveries with their delivery log, oldest first
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, jobID string) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, job_id, event_type, url, status, attempts, next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE job_id = $1
+		ORDER BY created_at
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	index := make(map[string]int)
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var nextAttempt time.Time
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.JobID, &d.EventType, &d.URL, &d.Status, &d.Attempts, &nextAttempt, &d.CreatedAt, &deliveredAt); err != nil {
+			return nil, err
+		}
+		if d.Status == models.WebhookStatusPending {
+			d.NextAttempt = &nextAttempt
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		d.Log = []models.WebhookDeliveryAttempt{}
+		index[d.ID] = len(deliveries)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	attemptRows, err := r.db.QueryContext(ctx, `
+		SELECT a.delivery_id, a.attempt, COALESCE(a.status_code, 0), COALESCE(a.error, ''), a.duration_ms, a.attempted_at
+		FROM webhook_delivery_attempts a
+		JOIN webhook_deliveries d ON d.id = a.delivery_id
+		WHERE d.job_id = $1
+		ORDER BY a.attempt
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var deliveryID string
+		var a models.WebhookDeliveryAttempt
+		if err := attemptRows.Scan(&deliveryID, &a.Attempt, &a.StatusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := index[deliveryID]; ok {
+			deliveries[i].Log = append(deliveries[i].Log, a)
+		}
+	}
+	return deliveries, attemptRows.Err()
+}
@@ -3,8 +3,12 @@ package router
 import (
 	"database/sql"
 	"user-service/internal/handlers"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func SetupRoutes(app *fiber.App, db *sql.DB) {
@@ -17,16 +21,47 @@ func SetupRoutes(app *fiber.App, db *sql.DB) {
 		})
 	})
 
-	api := app.Group("/v1")
+	// Prometheus metrics
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+
+	// Every /v1 route needs an API key and is rate limited per key; each route
+	// then checks the key's scope
+	api := app.Group("/v1", middleware.Authenticate(db), middleware.RateLimit(db))
+	translate := middleware.RequireScope(models.ScopeTranslate)
 
 	// Translation routes
-	api.Post("/translate", handlers.Translate(db))
-	api.Post("/translate/batch", handlers.TranslateBatch(db)) // translate multiple texts at once
-	api.Get("/languages", handlers.Languages(db))             // return the list of languages, like en,hi, all iso-639 codes from readme file
+	api.Post("/translate", translate, handlers.Translate(db))
+	api.Post("/translate/batch", translate, handlers.TranslateBatch(db))              // translate multiple texts at once
+	api.Post("/translate/batch/stream", translate, handlers.TranslateBatchStream(db)) // same, streaming each result as SSE or NDJSON
+	api.Post("/translate/markdown", translate, handlers.TranslateMarkdown(db))        // translate a Markdown document, keeping code and links intact
+	api.Post("/translate/subtitles", translate, handlers.TranslateSubtitles(db))      // translate SRT or WebVTT cue text, keeping timings
+	api.Post("/translate/csv", translate, handlers.TranslateCSV(db))                  // translate selected CSV columns into new <column>_<lang> columns
+	api.Post("/translate/docx", translate, handlers.TranslateDOCX(db))                // translate a Word document, keeping run formatting
+	api.Post("/translate/jsonl", translate, handlers.TranslateJSONL(db))              // translate a JSONL file of records, one result line per record
+	api.Get("/languages", translate, handlers.Languages(db))                          // return the list of languages, like en,hi, all iso-639 codes from readme file
+	api.Get("/languages/pairs", translate, handlers.LanguagePairs(db))                // supported language pairs with scripts, pipeline, service and model
+
+	// Live translation over WebSocket
+	api.Get("/ws/translate", translate, handlers.TranslateWebSocket(db)) // session with a language pair, results tagged with correlation IDs
+
+	// Asynchronous job routes
+	api.Post("/jobs", translate, handlers.CreateJob(db))                  // queue a batch or document, returns a job ID
+	api.Get("/jobs/:id", translate, handlers.GetJob(db))                  // job status and progress
+	api.Post("/jobs/:id/cancel", translate, handlers.CancelJob(db))       // cancel a queued or running job
+	api.Get("/jobs/:id/result", translate, handlers.GetJobResult(db))     // translations of a completed job
+	api.Get("/jobs/:id/webhooks", translate, handlers.GetJobWebhooks(db)) // webhook deliveries and their log
 
 	// manage routes
-	manage := api.Group("/manage")
+	manage := api.Group("/manage", middleware.RequireScope(models.ScopeManage))
 	manage.Post("/cache/clean", handlers.CleanCache(db))
+	manage.Post("/keys", handlers.CreateAPIKey(db))                // create a client API key, shown once
+	manage.Get("/keys", handlers.ListAPIKeys(db))                  // list keys by prefix, never the keys themselves
+	manage.Post("/keys/:id/revoke", handlers.RevokeAPIKey(db))     // revoke a key immediately
+	manage.Post("/keys/:id/rotate", handlers.RotateAPIKey(db))     // replace a key, optionally keeping the old one for a grace period
+	manage.Put("/keys/:id/limits", handlers.SetAPIKeyLimits(db))   // set a key's requests per second and character quota
+	manage.Get("/usage", handlers.GetUsage(db))                    // usage per client, pair and period, as JSON or CSV
+	manage.Get("/circuit-breakers", handlers.GetCircuitBreakers()) // state of the Bhashini circuit breakers
+	manage.Get("/pipelines", handlers.GetPipelines())              // Bhashini pipeline routes and health
 
 }
 
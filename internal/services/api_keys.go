@@ -0,0 +1,34 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// apiKeyTag starts every generated key, so leaked keys are easy to spot
+const apiKeyTag = "tsk_"
+
+// apiKeyPrefixLength is how much of a key is stored in clear to identify it
+const apiKeyPrefixLength = 12
+
+// GenerateAPIKey returns a new random API key with its display prefix and the
+// hash it is stored under
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key = apiKeyTag + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:apiKeyPrefixLength], HashAPIKey(key), nil
+}
+
+// HashAPIKey hashes a key for storage and lookup. Keys carry 256 bits of
+// randomness, so a plain SHA-256 is enough; no salt or slow hash is needed.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
@@ -2,16 +2,29 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"user-service/internal/constants"
+	"user-service/internal/logging"
+	"user-service/internal/metrics"
 	"user-service/internal/models"
+	"user-service/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // min returns the minimum of two integers
@@ -28,9 +41,13 @@ type BhashiniClient struct {
 	UserID     string
 	APIKey     string
 	HTTPClient *http.Client
+	Retry      RetryPolicy
+
+	retriesUsed atomic.Int64
 }
 
-// NewBhashiniClient creates a new Bhashini client
+// NewBhashiniClient creates a new Bhashini client. A client's retry budget is
+// shared by all its calls, so create one per request.
 func NewBhashiniClient() *BhashiniClient {
 	baseURL := os.Getenv("BHASHINI_BASE_URL")
 	if baseURL == "" {
@@ -53,11 +70,12 @@ func NewBhashiniClient() *BhashiniClient {
 		UserID:     userID,
 		APIKey:     apiKey,
 		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Retry:      DefaultRetryPolicy(),
 	}
 }
 
 // SearchPipelines searches for available pipelines that support translation
-func (c *BhashiniClient) SearchPipelines() (*models.PipelineSearchResponse, error) {
+func (c *BhashiniClient) SearchPipelines(ctx context.Context) (*models.PipelineSearchResponse, error) {
 	if c.UserID == "" || c.APIKey == "" {
 		return nil, errors.New("BHASHINI_USER_ID and BHASHINI_API_KEY must be set")
 	}
@@ -71,7 +89,7 @@ func (c *BhashiniClient) SearchPipelines() (*models.PipelineSearchResponse, erro
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", c.BaseURL+"/ulca/apis/v0/model/getModelsPipeline", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/ulca/apis/v0/model/getModelsPipeline", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -80,19 +98,13 @@ func (c *BhashiniClient) SearchPipelines() (*models.PipelineSearchResponse, erro
 	httpReq.Header.Set("userID", c.UserID)
 	httpReq.Header.Set("ulcaApiKey", c.APIKey)
 
-	resp, err := c.HTTPClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	statusCode, body, err := c.send(ctx, metrics.EndpointSearch, "", httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", statusCode, string(body))
 	}
 
 	var searchResp models.PipelineSearchResponse
@@ -105,27 +117,8 @@ func (c *BhashiniClient) SearchPipelines() (*models.PipelineSearchResponse, erro
 	return &searchResp, nil
 }
 
-// FindTranslationPipeline finds a valid pipeline ID for translation
-// Returns the first known valid pipeline ID for translation
-func (c *BhashiniClient) FindTranslationPipeline() (string, error) {
-	// Known valid pipeline IDs for translation (from Bhashini documentation)
-	// These are pre-validated pipeline IDs that support translation
-	validPipelines := []string{
-		"64392f96daac500b55c543cd", // Initial Pipeline (supports Translation, ASR, Transliteration, TTS)
-		"660f813c0413087224435d2c", // IIT Bombay (Translation)
-		"660f866443e53d4133f65317", // IIIT Hyderabad (Translation)
-	}
-
-	// Return the first one (Initial Pipeline is most comprehensive)
-	if len(validPipelines) > 0 {
-		return validPipelines[0], nil
-	}
-
-	return "", errors.New("no valid translation pipeline found")
-}
-
 // GetPipelineConfig retrieves pipeline configuration for translation
-func (c *BhashiniClient) GetPipelineConfig(pipelineID, sourceLang, targetLang string) (*models.PipelineConfigResponse, error) {
+func (c *BhashiniClient) GetPipelineConfig(ctx context.Context, pipelineID, sourceLang, targetLang string) (*models.PipelineConfigResponse, error) {
 	if c.UserID == "" {
 		return nil, errors.New("BHASHINI_USER_ID is not set or empty")
 	}
@@ -150,7 +143,7 @@ func (c *BhashiniClient) GetPipelineConfig(pipelineID, sourceLang, targetLang st
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", c.BaseURL+"/ulca/apis/v0/model/getModelsPipeline", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/ulca/apis/v0/model/getModelsPipeline", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -159,50 +152,59 @@ func (c *BhashiniClient) GetPipelineConfig(pipelineID, sourceLang, targetLang st
 	httpReq.Header.Set("userID", c.UserID)
 	httpReq.Header.Set("ulcaApiKey", c.APIKey)
 
-	// Debug logging (only in development - can be removed or made conditional)
-	// fmt.Printf("[DEBUG] Making request with UserID: %s, APIKey length: %d\n", c.UserID, len(c.APIKey))
-
-	resp, err := c.HTTPClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	statusCode, body, err := c.send(ctx, metrics.EndpointConfig, pipelineID, httpReq,
+		tracing.AttrPipelineID.String(pipelineID), tracing.AttrSourceLang.String(sourceLang), tracing.AttrTargetLang.String(targetLang))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if statusCode != http.StatusOK {
 		// Provide more helpful error message for 400 errors
-		if resp.StatusCode == 400 {
-			return nil, fmt.Errorf("API returned status %d: %s. Verify: 1) API key is the 'ulcaApiKey' from 'My Profile' section (not other keys), 2) Key is active/enabled in dashboard, 3) No extra spaces or quotes in .env file", resp.StatusCode, string(body))
+		if statusCode == 400 {
+			return nil, fmt.Errorf("API returned status %d: %s. Verify: 1) API key is the 'ulcaApiKey' from 'My Profile' section (not other keys), 2) Key is active/enabled in dashboard, 3) No extra spaces or quotes in .env file", statusCode, string(body))
 		}
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API returned status %d: %s", statusCode, string(body))
 	}
 
 	var configResp models.PipelineConfigResponse
 	if err := json.Unmarshal(body, &configResp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	configResp.PipelineID = pipelineID
 
 	return &configResp, nil
 }
 
 // Translate performs translation using Bhashini API
-func (c *BhashiniClient) Translate(config *models.PipelineConfigResponse, sourceText, sourceLang, targetLang string) (*models.PipelineComputeResponse, error) {
+func (c *BhashiniClient) Translate(ctx context.Context, config *models.PipelineConfigResponse, sourceText, sourceLang, targetLang string) (*models.PipelineComputeResponse, error) {
+	return c.TranslateTexts(ctx, config, []string{sourceText}, sourceLang, targetLang)
+}
+
+// TranslateTexts translates several texts in a single compute call. The
+// pipeline returns one output item per input item, in the same order.
+func (c *BhashiniClient) TranslateTexts(ctx context.Context, config *models.PipelineConfigResponse, sourceTexts []string, sourceLang, targetLang string) (*models.PipelineComputeResponse, error) {
+	if len(sourceTexts) == 0 {
+		return nil, errors.New("no source texts to translate")
+	}
 	if config.PipelineInferenceAPIEndPoint.CallbackURL == "" {
 		return nil, errors.New("callback URL not found in pipeline config")
 	}
 
+	// Language tags such as "mni-Beng" are sent as a code and a script
+	sourceCode, sourceScript := constants.SplitLanguage(sourceLang)
+	targetCode, targetScript := constants.SplitLanguage(targetLang)
+
 	// Extract service ID from config
 	// Find the translation task config
 	var serviceID string
 	for _, taskConfig := range config.PipelineResponseConfig {
 		if taskConfig.TaskType == "translation" && len(taskConfig.Config) > 0 {
-			// Find config matching source and target language
+			// Find config matching source and target language, and their
+			// scripts when the config lists them
 			for _, cfg := range taskConfig.Config {
-				if cfg.Language.SourceLanguage == sourceLang && cfg.Language.TargetLanguage == targetLang {
+				if cfg.Language.SourceLanguage == sourceCode && cfg.Language.TargetLanguage == targetCode &&
+					(cfg.Language.SourceScriptCode == "" || cfg.Language.SourceScriptCode == sourceScript) &&
+					(cfg.Language.TargetScriptCode == "" || cfg.Language.TargetScriptCode == targetScript) {
 					serviceID = cfg.ServiceID
 					break
 				}
@@ -219,23 +221,28 @@ func (c *BhashiniClient) Translate(config *models.PipelineConfigResponse, source
 		return nil, errors.New("could not find service ID for translation task")
 	}
 
+	inputs := make([]models.InputItem, len(sourceTexts))
+	for i, text := range sourceTexts {
+		inputs[i] = models.InputItem{Source: text}
+	}
+
 	req := models.PipelineComputeRequest{
 		PipelineTasks: []models.PipelineComputeTask{
 			{
 				TaskType: "translation",
 				Config: models.PipelineComputeTaskConfig{
 					Language: models.TaskLanguage{
-						SourceLanguage: sourceLang,
-						TargetLanguage: targetLang,
+						SourceLanguage:   sourceCode,
+						SourceScriptCode: sourceScript,
+						TargetLanguage:   targetCode,
+						TargetScriptCode: targetScript,
 					},
 					ServiceID: serviceID,
 				},
 			},
 		},
 		InputData: models.InputData{
-			Input: []models.InputItem{
-				{Source: sourceText},
-			},
+			Input: inputs,
 		},
 	}
 
@@ -244,7 +251,7 @@ func (c *BhashiniClient) Translate(config *models.PipelineConfigResponse, source
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", config.PipelineInferenceAPIEndPoint.CallbackURL, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", config.PipelineInferenceAPIEndPoint.CallbackURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -257,23 +264,15 @@ func (c *BhashiniClient) Translate(config *models.PipelineConfigResponse, source
 		httpReq.Header.Set(authKeyName, authKeyValue)
 	}
 
-	// Debug logging (only in development - can be removed or made conditional)
-	// fmt.Printf("[DEBUG] Translation request URL: %s\n", config.PipelineInferenceAPIEndPoint.CallbackURL)
-	// fmt.Printf("[DEBUG] Translation request payload: %s\n", string(jsonData))
-
-	resp, err := c.HTTPClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	metrics.UpstreamBatchSize.Observe(float64(len(sourceTexts)))
+	statusCode, body, err := c.send(ctx, metrics.EndpointCompute, config.PipelineID, httpReq,
+		tracing.AttrServiceID.String(serviceID), tracing.AttrSourceLang.String(sourceLang), tracing.AttrTargetLang.String(targetLang))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s. Request payload was: %s", resp.StatusCode, string(body), string(jsonData))
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s. Request payload was: %s", statusCode, string(body), string(jsonData))
 	}
 
 	var computeResp models.PipelineComputeResponse
@@ -283,3 +282,133 @@ func (c *BhashiniClient) Translate(config *models.PipelineConfigResponse, source
 
 	return &computeResp, nil
 }
+
+// send executes a request against one of the Bhashini endpoints and reads the
+// response, retrying failures the retry policy allows while the client's
+// retry budget lasts. Calls to an endpoint and pipeline whose circuit is open
+// fail fast with a CircuitOpenError.
+func (c *BhashiniClient) send(ctx context.Context, endpoint, pipelineID string, httpReq *http.Request, attrs ...attribute.KeyValue) (int, []byte, error) {
+	for attempt := 1; ; attempt++ {
+		statusCode, body, retryAfter, err := c.attempt(ctx, endpoint, pipelineID, httpReq, attempt, attrs)
+		if (err == nil && statusCode == http.StatusOK) || attempt >= c.Retry.MaxAttempts || !retryable(statusCode, err) || ctx.Err() != nil {
+			return statusCode, body, err
+		}
+
+		delay, ok := c.Retry.delay(attempt, retryAfter)
+		if !ok || !c.takeRetry() {
+			return statusCode, body, err
+		}
+		metrics.UpstreamRetries.WithLabelValues(endpoint).Inc()
+		logging.FromContext(ctx).Info("Retrying Bhashini call", "endpoint", endpoint, "attempt", attempt+1, "delay_ms", delay.Milliseconds())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, nil, fmt.Errorf("waiting to retry: %w", ctx.Err())
+		case <-timer.C:
+		}
+
+		// The body was read by the failed attempt; send a fresh copy
+		retryReq := httpReq.Clone(ctx)
+		if httpReq.GetBody != nil {
+			if retryReq.Body, err = httpReq.GetBody(); err != nil {
+				return 0, nil, fmt.Errorf("failed to create request: %w", err)
+			}
+		}
+		httpReq = retryReq
+	}
+}
+
+// takeRetry uses up one retry of the client's budget, reporting false when
+// none are left
+func (c *BhashiniClient) takeRetry() bool {
+	return c.retriesUsed.Add(1) <= int64(c.Retry.Budget)
+}
+
+// resetRetryBudget gives the client its whole retry budget again
+func (c *BhashiniClient) resetRetryBudget() {
+	c.retriesUsed.Store(0)
+}
+
+// attempt makes one attempt at a call, recording its latency, status and
+// errors, and tracing it in a client span carrying attrs. The request ID of
+// ctx is forwarded in the X-Request-ID header. Only the endpoint and outcome
+// are logged, never the headers, which hold credentials.
+func (c *BhashiniClient) attempt(ctx context.Context, endpoint, pipelineID string, httpReq *http.Request, attempt int, attrs []attribute.KeyValue) (int, []byte, time.Duration, error) {
+	recordOutcome, err := upstreamCircuitBreakers().allow(endpoint, pipelineID)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	inFlight := metrics.UpstreamInFlight.WithLabelValues(endpoint)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	ctx, span := tracing.Start(ctx, "bhashini "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			tracing.AttrEndpoint.String(endpoint),
+			semconv.HTTPRequestMethodKey.String(httpReq.Method),
+			semconv.ServerAddress(httpReq.URL.Hostname()),
+		),
+		trace.WithAttributes(attrs...),
+	)
+	if attempt > 1 {
+		span.SetAttributes(semconv.HTTPRequestResendCount(attempt - 1))
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		httpReq.Header.Set(logging.HeaderRequestID, requestID)
+	}
+
+	start := time.Now()
+	statusCode, body, retryAfter, err := c.roundTrip(httpReq)
+	failure := upstreamFailure(statusCode, err)
+	if failure != nil && ctx.Err() != nil {
+		failure = errCallAbandoned
+	}
+	recordOutcome(failure)
+	if statusCode != 0 {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+	}
+	if err == nil && statusCode != http.StatusOK {
+		tracing.End(span, fmt.Errorf("API returned status %d", statusCode))
+	} else {
+		tracing.End(span, err)
+	}
+
+	status := metrics.StatusNetworkError
+	if statusCode != 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	elapsed := time.Since(start)
+	metrics.UpstreamRequestDuration.WithLabelValues(endpoint, status).Observe(elapsed.Seconds())
+	logger := logging.FromContext(ctx).With("endpoint", endpoint, "status", status, "attempt", attempt, "latency_ms", elapsed.Milliseconds())
+	if err != nil || statusCode != http.StatusOK {
+		metrics.UpstreamErrors.WithLabelValues(endpoint, status).Inc()
+		if err != nil {
+			logger = logger.With("error", err.Error())
+		}
+		logger.Warn("Bhashini call failed")
+	} else {
+		logger.Debug("Bhashini call")
+	}
+	return statusCode, body, retryAfter, err
+}
+
+// roundTrip executes a request and reads the whole response, along with the
+// wait any Retry-After header asks for
+func (c *BhashiniClient) roundTrip(httpReq *http.Request) (int, []byte, time.Duration, error) {
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, body, parseRetryAfter(resp.Header), nil
+}
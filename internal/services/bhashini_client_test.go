@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBhashiniClientAbandonsCallsAtDeadline(t *testing.T) {
+	fake := newFakeBhashini(t, func(text string) string {
+		time.Sleep(time.Second)
+		return upperCase(text)
+	})
+	client := fake.client()
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 10}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewTranslationService(client, nil).Translate(ctx, "Hello", "en", "hi")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Translate() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Translate() took %s after its deadline", elapsed)
+	}
+	if calls := len(fake.computeInputs()); calls != 1 {
+		t.Errorf("compute called %d times, want the abandoned call not retried", calls)
+	}
+}
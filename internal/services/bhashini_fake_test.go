@@ -0,0 +1,120 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// The circuit breakers and pipeline router are shared by the whole
+	// process, so tests that fail upstream on purpose would otherwise open
+	// circuits for the tests after them. One pipeline per pair keeps the
+	// compute calls of a translation countable.
+	os.Setenv("CIRCUIT_BREAKER_THRESHOLD", "0")
+	os.Setenv("BHASHINI_PIPELINE_ID", "")
+	os.Setenv("BHASHINI_PIPELINE_ROUTES", "*:*=initial")
+	os.Exit(m.Run())
+}
+
+// fakeBhashini stands in for Bhashini, serving the pipeline config and
+// compute endpoints. Compute translates every input with translate, unless
+// failWith has set a status to answer with instead.
+type fakeBhashini struct {
+	*httptest.Server
+	translate func(string) string
+
+	mu     sync.Mutex
+	status int
+	inputs [][]string // inputs of each compute call
+}
+
+// newFakeBhashini starts a fake Bhashini that translates with translate
+func newFakeBhashini(t *testing.T, translate func(string) string) *fakeBhashini {
+	t.Helper()
+	f := &fakeBhashini{translate: translate}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ulca/apis/v0/model/getModelsPipeline", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"pipelineInferenceAPIEndPoint":{"callbackUrl":%q,"inferenceApiKey":{"name":"Authorization","value":"key"}},`+
+			`"pipelineResponseConfig":[{"taskType":"translation","config":[{"serviceId":"fake","language":{"sourceLanguage":"en","targetLanguage":"hi"}}]}]}`,
+			f.URL+"/compute")
+	})
+	mux.HandleFunc("/compute", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			InputData struct {
+				Input []struct {
+					Source string `json:"source"`
+				} `json:"input"`
+			} `json:"inputData"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f.mu.Lock()
+		status := f.status
+		var inputs []string
+		for _, input := range req.InputData.Input {
+			inputs = append(inputs, input.Source)
+		}
+		f.inputs = append(f.inputs, inputs)
+		f.mu.Unlock()
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+
+		output := make([]map[string]string, len(inputs))
+		for i, input := range inputs {
+			output[i] = map[string]string{"source": input, "target": f.translate(input)}
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"pipelineResponse": []any{map[string]any{"taskType": "translation", "output": output}},
+		})
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// failWith makes compute answer with a status, or translate again when it is 0
+func (f *fakeBhashini) failWith(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+// computeInputs returns the inputs of every compute call so far
+func (f *fakeBhashini) computeInputs() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.inputs...)
+}
+
+// client returns a Bhashini client of the fake that does not retry
+func (f *fakeBhashini) client() *BhashiniClient {
+	client := NewBhashiniClient()
+	client.BaseURL = f.URL
+	client.UserID = "user"
+	client.APIKey = strings.Repeat("k", 32)
+	client.Retry.MaxAttempts = 1
+	return client
+}
+
+// service returns a translation service of the fake without a cache
+func (f *fakeBhashini) service() *TranslationService {
+	return NewTranslationService(f.client(), nil)
+}
+
+// upperCase is a stand-in translation that keeps placeholders intact
+func upperCase(text string) string {
+	return strings.ToUpper(text)
+}
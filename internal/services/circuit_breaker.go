@@ -0,0 +1,275 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"    // calls go upstream
+	CircuitOpen     = "open"      // calls fail fast
+	CircuitHalfOpen = "half_open" // a few probe calls test whether upstream recovered
+)
+
+// Circuit breaker defaults, overridden by the CIRCUIT_BREAKER_* variables
+const (
+	defaultCircuitThreshold        = 5
+	defaultCircuitCooldown         = 30 * time.Second
+	defaultCircuitHalfOpenRequests = 1
+)
+
+// CircuitBreakerConfig configures every upstream circuit breaker
+type CircuitBreakerConfig struct {
+	Threshold        int           // consecutive failures that open a circuit; 0 disables the breakers
+	Cooldown         time.Duration // how long an open circuit fails fast before probing
+	HalfOpenRequests int           // probe calls let through at once while half open
+}
+
+// CircuitBreakerStatus is the state of one upstream circuit
+type CircuitBreakerStatus struct {
+	Endpoint            string     `json:"endpoint"`
+	PipelineID          string     `json:"pipeline_id,omitempty"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// CircuitOpenError is returned instead of calling an upstream endpoint whose
+// circuit is open
+type CircuitOpenError struct {
+	Endpoint   string
+	PipelineID string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	target := "Bhashini " + e.Endpoint + " endpoint"
+	if e.PipelineID != "" {
+		target += " for pipeline " + e.PipelineID
+	}
+	return fmt.Sprintf("%s is unavailable after repeated failures, retry in %s", target, e.RetryAfter.Round(time.Second))
+}
+
+// errCallAbandoned is the outcome of a call whose caller gave up on it, by
+// disconnecting or running out of time; it says nothing about upstream's
+// health either way
+var errCallAbandoned = errors.New("call abandoned by the caller")
+
+// circuitKey identifies the circuit of one upstream endpoint and pipeline
+type circuitKey struct {
+	endpoint   string
+	pipelineID string
+}
+
+// circuitBreaker tracks the health of one upstream endpoint and pipeline
+type circuitBreaker struct {
+	mu            sync.Mutex
+	state         string
+	failures      int // consecutive failures
+	probes        int // half-open calls in flight
+	openedAt      time.Time
+	lastFailureAt time.Time
+	lastError     string
+}
+
+// CircuitBreakers holds the breakers of the upstream endpoints. They are
+// shared by every Bhashini client in the process, so one request's failures
+// protect the others.
+type CircuitBreakers struct {
+	config   CircuitBreakerConfig
+	mu       sync.Mutex
+	breakers map[circuitKey]*circuitBreaker
+}
+
+// NewCircuitBreakers creates an empty set of breakers
+func NewCircuitBreakers(config CircuitBreakerConfig) *CircuitBreakers {
+	return &CircuitBreakers{config: config, breakers: make(map[circuitKey]*circuitBreaker)}
+}
+
+// upstreamCircuitBreakers returns the process's breakers, configured from the
+// environment on first use
+var upstreamCircuitBreakers = sync.OnceValue(func() *CircuitBreakers {
+	config := CircuitBreakerConfig{
+		Threshold:        defaultCircuitThreshold,
+		Cooldown:         defaultCircuitCooldown,
+		HalfOpenRequests: defaultCircuitHalfOpenRequests,
+	}
+	if value := os.Getenv("CIRCUIT_BREAKER_THRESHOLD"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			config.Threshold = parsed
+		}
+	}
+	if value := os.Getenv("CIRCUIT_BREAKER_COOLDOWN"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			config.Cooldown = parsed
+		}
+	}
+	if value := os.Getenv("CIRCUIT_BREAKER_HALF_OPEN_REQUESTS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			config.HalfOpenRequests = parsed
+		}
+	}
+	return NewCircuitBreakers(config)
+})
+
+// UpstreamCircuitBreakers reports the configuration of the upstream breakers
+// and the state of every circuit used so far
+func UpstreamCircuitBreakers() (CircuitBreakerConfig, []CircuitBreakerStatus) {
+	breakers := upstreamCircuitBreakers()
+	return breakers.config, breakers.Statuses()
+}
+
+// Statuses returns the state of every circuit, by endpoint and pipeline
+func (b *CircuitBreakers) Statuses() []CircuitBreakerStatus {
+	b.mu.Lock()
+	keys := make([]circuitKey, 0, len(b.breakers))
+	for key := range b.breakers {
+		keys = append(keys, key)
+	}
+	b.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].pipelineID < keys[j].pipelineID
+	})
+
+	statuses := make([]CircuitBreakerStatus, len(keys))
+	for i, key := range keys {
+		statuses[i] = b.get(key).status(key, b.config)
+	}
+	return statuses
+}
+
+// get returns the breaker of a circuit, creating it closed
+func (b *CircuitBreakers) get(key circuitKey) *circuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker := b.breakers[key]
+	if breaker == nil {
+		breaker = &circuitBreaker{state: CircuitClosed}
+		b.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// allow asks whether a call may go upstream. It returns a CircuitOpenError
+// when it may not, and otherwise a function to report the call's outcome
+// with.
+func (b *CircuitBreakers) allow(endpoint, pipelineID string) (func(failure error), error) {
+	if b.config.Threshold == 0 {
+		return func(error) {}, nil
+	}
+
+	key := circuitKey{endpoint: endpoint, pipelineID: pipelineID}
+	breaker := b.get(key)
+	probe, retryAfter := breaker.allow(b.config)
+	if retryAfter > 0 {
+		return nil, &CircuitOpenError{Endpoint: endpoint, PipelineID: pipelineID, RetryAfter: retryAfter}
+	}
+	return func(failure error) {
+		breaker.record(b.config, probe, failure)
+	}, nil
+}
+
+// allow lets a call through, reporting whether it is a half-open probe, or
+// returns how long to wait before the circuit may let calls through again
+func (cb *circuitBreaker) allow(config CircuitBreakerConfig) (probe bool, retryAfter time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	if cb.state == CircuitOpen {
+		if wait := cb.openedAt.Add(config.Cooldown).Sub(now); wait > 0 {
+			return false, wait
+		}
+		cb.state = CircuitHalfOpen
+		cb.probes = 0
+	}
+	if cb.state == CircuitHalfOpen {
+		if cb.probes >= config.HalfOpenRequests {
+			// Probes are under way; their outcome decides soon
+			return false, time.Second
+		}
+		cb.probes++
+		return true, 0
+	}
+	return false, 0
+}
+
+// record updates the circuit with the outcome of a call it let through
+func (cb *circuitBreaker) record(config CircuitBreakerConfig, probe bool, failure error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if probe {
+		cb.probes--
+	}
+	if errors.Is(failure, errCallAbandoned) {
+		return
+	}
+	if failure == nil {
+		if probe || cb.state == CircuitClosed {
+			cb.state = CircuitClosed
+			cb.failures = 0
+		}
+		return
+	}
+
+	cb.failures++
+	cb.lastFailureAt = time.Now()
+	cb.lastError = failure.Error()
+	if probe || (cb.state == CircuitClosed && cb.failures >= config.Threshold) {
+		cb.state = CircuitOpen
+		cb.openedAt = cb.lastFailureAt
+	}
+}
+
+// status reports the state of the circuit
+func (cb *circuitBreaker) status(key circuitKey, config CircuitBreakerConfig) CircuitBreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	status := CircuitBreakerStatus{
+		Endpoint:            key.endpoint,
+		PipelineID:          key.pipelineID,
+		State:               cb.state,
+		ConsecutiveFailures: cb.failures,
+		LastError:           cb.lastError,
+	}
+	if cb.state == CircuitOpen {
+		openedAt := cb.openedAt
+		retryAt := openedAt.Add(config.Cooldown)
+		status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	}
+	if !cb.lastFailureAt.IsZero() {
+		lastFailureAt := cb.lastFailureAt
+		status.LastFailureAt = &lastFailureAt
+	}
+	return status
+}
+
+// upstreamFailure returns the error that counts against a circuit for a
+// call's outcome: network errors and 5xx responses. 4xx responses, 429
+// included, are answers about the request or its pace, not signs that
+// upstream is down.
+func upstreamFailure(statusCode int, err error) error {
+	if err != nil {
+		return err
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("API returned status %d", statusCode)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// circuitState returns the state of a circuit that has been used
+func circuitState(t *testing.T, breakers *CircuitBreakers, endpoint, pipelineID string) string {
+	t.Helper()
+	for _, status := range breakers.Statuses() {
+		if status.Endpoint == endpoint && status.PipelineID == pipelineID {
+			return status.State
+		}
+	}
+	t.Fatalf("no circuit for %s %s", endpoint, pipelineID)
+	return ""
+}
+
+// failCalls lets n calls through and reports each as failed
+func failCalls(t *testing.T, breakers *CircuitBreakers, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		done, err := breakers.allow("compute", "p1")
+		if err != nil {
+			t.Fatalf("call %d refused: %v", i+1, err)
+		}
+		done(errors.New("API returned status 502"))
+	}
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	breakers := NewCircuitBreakers(CircuitBreakerConfig{Threshold: 3, Cooldown: time.Hour, HalfOpenRequests: 1})
+
+	failCalls(t, breakers, 2)
+	if state := circuitState(t, breakers, "compute", "p1"); state != CircuitClosed {
+		t.Fatalf("state after 2 failures = %s, want %s", state, CircuitClosed)
+	}
+
+	// A success resets the count of consecutive failures
+	done, err := breakers.allow("compute", "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(nil)
+	failCalls(t, breakers, 2)
+	if state := circuitState(t, breakers, "compute", "p1"); state != CircuitClosed {
+		t.Fatalf("state after a success and 2 failures = %s, want %s", state, CircuitClosed)
+	}
+
+	failCalls(t, breakers, 1)
+	if state := circuitState(t, breakers, "compute", "p1"); state != CircuitOpen {
+		t.Fatalf("state after 3 failures = %s, want %s", state, CircuitOpen)
+	}
+
+	_, err = breakers.allow("compute", "p1")
+	var circuitErr *CircuitOpenError
+	if !errors.As(err, &circuitErr) {
+		t.Fatalf("allow() on an open circuit = %v, want a CircuitOpenError", err)
+	}
+	if circuitErr.RetryAfter <= 0 || circuitErr.RetryAfter > time.Hour {
+		t.Errorf("RetryAfter = %s, want up to the cooldown", circuitErr.RetryAfter)
+	}
+
+	// Circuits are kept per endpoint and pipeline
+	if _, err := breakers.allow("compute", "p2"); err != nil {
+		t.Errorf("allow() for another pipeline = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome error
+		want    string
+	}{
+		{"successful probe closes", nil, CircuitClosed},
+		{"failed probe reopens", errors.New("connection refused"), CircuitOpen},
+		{"abandoned probe stays half open", fmt.Errorf("compute: %w", errCallAbandoned), CircuitHalfOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakers := NewCircuitBreakers(CircuitBreakerConfig{Threshold: 1, Cooldown: 10 * time.Millisecond, HalfOpenRequests: 1})
+			failCalls(t, breakers, 1)
+			time.Sleep(20 * time.Millisecond)
+
+			probe, err := breakers.allow("compute", "p1")
+			if err != nil {
+				t.Fatalf("probe refused after the cooldown: %v", err)
+			}
+			if state := circuitState(t, breakers, "compute", "p1"); state != CircuitHalfOpen {
+				t.Fatalf("state during the probe = %s, want %s", state, CircuitHalfOpen)
+			}
+			// Only HalfOpenRequests probes are let through at once
+			if _, err := breakers.allow("compute", "p1"); err == nil {
+				t.Fatal("second call let through while the probe is under way")
+			}
+
+			probe(tt.outcome)
+			if state := circuitState(t, breakers, "compute", "p1"); state != tt.want {
+				t.Errorf("state after the probe = %s, want %s", state, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breakers := NewCircuitBreakers(CircuitBreakerConfig{Threshold: 0, Cooldown: time.Hour, HalfOpenRequests: 1})
+	for i := 0; i < 10; i++ {
+		done, err := breakers.allow("compute", "p1")
+		if err != nil {
+			t.Fatalf("call %d refused with breakers disabled: %v", i+1, err)
+		}
+		done(errors.New("API returned status 502"))
+	}
+	if statuses := breakers.Statuses(); len(statuses) != 0 {
+		t.Errorf("Statuses() = %v, want none with breakers disabled", statuses)
+	}
+}
+
+func TestUpstreamFailure(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		err        error
+		failure    bool
+	}{
+		{http.StatusOK, nil, false},
+		{http.StatusBadRequest, nil, false},
+		{http.StatusTooManyRequests, nil, false},
+		{http.StatusInternalServerError, nil, true},
+		{http.StatusBadGateway, nil, true},
+		{0, errors.New("connection reset"), true},
+	}
+	for _, tt := range tests {
+		if got := upstreamFailure(tt.statusCode, tt.err); (got != nil) != tt.failure {
+			t.Errorf("upstreamFailure(%d, %v) = %v, want failure %v", tt.statusCode, tt.err, got, tt.failure)
+		}
+	}
+}
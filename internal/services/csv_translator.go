@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// csvBatchRows is how many rows are read before their cells are translated.
+// Only one batch is held in memory at a time.
+const csvBatchRows = 100
+
+// ErrUnknownCSVColumn is returned when a requested column is not in the header
+var ErrUnknownCSVColumn = errors.New("column not found in CSV header")
+
+// CSVOptions configures a CSV translation
+type CSVOptions struct {
+	Delimiter   rune
+	Columns     []string
+	SourceLang  string
+	TargetLangs []string
+}
+
+// CSVTranslator adds translated columns to CSV files. For every selected
+// column and target language a new column named <column>_<lang> is appended;
+// the original columns are written back unchanged.
+type CSVTranslator struct {
+	translationService *TranslationService
+}
+
+// NewCSVTranslator creates a new CSV translator
+func NewCSVTranslator(translationService *TranslationService) *CSVTranslator {
+	return &CSVTranslator{translationService: translationService}
+}
+
+// Translate reads CSV from r and writes the translated CSV to w. Rows are
+// processed in batches as they are read, and each batch goes upstream through
+// the batch compute path, one request per column and target language.
+func (t *CSVTranslator) Translate(ctx context.Context, r io.Reader, w io.Writer, opts CSVOptions) error {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.Delimiter
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.Delimiter
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return errors.New("CSV file is empty")
+		}
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columnIndexes := make([]int, len(opts.Columns))
+	for i, column := range opts.Columns {
+		columnIndexes[i] = -1
+		for j, name := range header {
+			if name == column {
+				columnIndexes[i] = j
+				break
+			}
+		}
+		if columnIndexes[i] == -1 {
+			return fmt.Errorf("%w: %s", ErrUnknownCSVColumn, column)
+		}
+	}
+
+	outputHeader := append([]string{}, header...)
+	for _, column := range opts.Columns {
+		for _, lang := range opts.TargetLangs {
+			outputHeader = append(outputHeader, column+"_"+lang)
+		}
+	}
+	if err := writer.Write(outputHeader); err != nil {
+		return err
+	}
+
+	batch := make([][]string, 0, csvBatchRows)
+	for {
+		record, err := reader.Read()
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read CSV row: %w", err)
+		}
+		if record != nil {
+			batch = append(batch, record)
+		}
+
+		if len(batch) == csvBatchRows || (err == io.EOF && len(batch) > 0) {
+			if err := t.translateBatch(ctx, batch, columnIndexes, opts); err != nil {
+				return err
+			}
+			if err := writer.WriteAll(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// translateBatch appends the translated cells to every row of the batch
+func (t *CSVTranslator) translateBatch(ctx context.Context, batch [][]string, columnIndexes []int, opts CSVOptions) error {
+	texts := make([]string, len(batch))
+	for k, columnIndex := range columnIndexes {
+		for i, record := range batch {
+			texts[i] = record[columnIndex]
+		}
+
+		for _, lang := range opts.TargetLangs {
+			translated, err := t.translationService.TranslateTexts(ctx, texts, opts.SourceLang, lang)
+			if err != nil {
+				return fmt.Errorf("failed to translate column %s to %s: %w", opts.Columns[k], lang, err)
+			}
+			for i := range batch {
+				batch[i] = append(batch[i], translated[i])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCSVTranslatorAppendsColumns(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	translator := NewCSVTranslator(fake.service())
+
+	input := "id;name;note\n1;apple;\"red; round\"\n2;pear;\n"
+	var output bytes.Buffer
+	err := translator.Translate(context.Background(), strings.NewReader(input), &output, CSVOptions{
+		Delimiter:   ';',
+		Columns:     []string{"note", "name"},
+		SourceLang:  "en",
+		TargetLangs: []string{"hi"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "id;name;note;note_hi;name_hi\n" +
+		"1;apple;\"red; round\";\"RED; ROUND\";APPLE\n" +
+		"2;pear;;;PEAR\n"
+	if output.String() != want {
+		t.Errorf("output = %q, want %q", output.String(), want)
+	}
+}
+
+func TestCSVTranslatorUnknownColumn(t *testing.T) {
+	translator := NewCSVTranslator(newFakeBhashini(t, upperCase).service())
+	err := translator.Translate(context.Background(), strings.NewReader("id,name\n1,apple\n"), &bytes.Buffer{}, CSVOptions{
+		Delimiter:   ',',
+		Columns:     []string{"title"},
+		SourceLang:  "en",
+		TargetLangs: []string{"hi"},
+	})
+	if !errors.Is(err, ErrUnknownCSVColumn) {
+		t.Errorf("Translate() = %v, want ErrUnknownCSVColumn", err)
+	}
+}
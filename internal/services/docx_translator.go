@@ -0,0 +1,355 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidDOCX is returned when the upload is not a readable .docx file
+var ErrInvalidDOCX = errors.New("file is not a valid .docx document")
+
+// defaultDOCXMaxPartSize is the largest uncompressed text part read when
+// DOCX_MAX_PART_SIZE is not set
+const defaultDOCXMaxPartSize = 64 << 20
+
+// docxTextParts matches the WordprocessingML parts whose text is translated:
+// the body, headers, footers, footnotes and endnotes
+var docxTextParts = regexp.MustCompile(`^word/(document|header\d*|footer\d*|footnotes|endnotes)\.xml$`)
+
+// docxTagPattern matches the elements that shape paragraph text: paragraphs,
+// runs, run properties and text nodes. The \b keeps w:pPr, w:rPr and w:tab
+// from matching w:p, w:r and w:t.
+var docxTagPattern = regexp.MustCompile(`<(/?)(w:p|w:r|w:rPr|w:t)\b[^>]*?(/?)>`)
+
+// docxMergeableGap matches markup that may sit between two text nodes without
+// separating them: run boundaries, proofing marks and rendering hints
+var docxMergeableGap = regexp.MustCompile(`^(?:</w:t>|</w:r>|<w:r\b[^>]*>|<w:rPr>.*?</w:rPr>|<w:proofErr\b[^>]*/>|<w:lastRenderedPageBreak/>|\s)*$`)
+
+// docxRevisionAttrs matches revision-session attributes, which Word changes
+// on every edit and which do not affect formatting
+var docxRevisionAttrs = regexp.MustCompile(`\s+w:rsid\w*="[^"]*"`)
+
+// DOCXTranslator translates Word documents. Paragraph text is translated as a
+// whole, while runs with different formatting are kept apart with protected
+// boundaries so bold, italics and other run properties land on the translated
+// words. Everything outside <w:t> text nodes is copied unchanged.
+type DOCXTranslator struct {
+	translationService *TranslationService
+	maxPartSize        int64
+}
+
+// NewDOCXTranslator creates a new DOCX translator
+func NewDOCXTranslator(translationService *TranslationService) *DOCXTranslator {
+	// Parse the largest uncompressed part read into memory (default 64 MiB)
+	maxPartSize := int64(defaultDOCXMaxPartSize)
+	if sizeStr := os.Getenv("DOCX_MAX_PART_SIZE"); sizeStr != "" {
+		if parsed, err := strconv.ParseInt(sizeStr, 10, 64); err == nil && parsed > 0 {
+			maxPartSize = parsed
+		}
+	}
+
+	return &DOCXTranslator{translationService: translationService, maxPartSize: maxPartSize}
+}
+
+// docxText is one <w:t> element: the offset of its start tag, the content
+// range and the formatting of the run it belongs to
+type docxText struct {
+	tagStart     int
+	contentStart int
+	contentEnd   int
+	format       string
+}
+
+// docxParagraph groups the text nodes of a paragraph into segments of equal
+// formatting, each of which is a separately placed piece of the translation
+type docxParagraph struct {
+	segments [][]docxText
+}
+
+// Translate reads a .docx from r and writes the translated document to w
+func (t *DOCXTranslator) Translate(ctx context.Context, r io.ReaderAt, size int64, w io.Writer, sourceLang, targetLang string) error {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDOCX, err)
+	}
+
+	hasDocument := false
+	for _, file := range archive.File {
+		if file.Name == "word/document.xml" {
+			hasDocument = true
+			break
+		}
+	}
+	if !hasDocument {
+		return fmt.Errorf("%w: word/document.xml is missing", ErrInvalidDOCX)
+	}
+
+	output := zip.NewWriter(w)
+	for _, file := range archive.File {
+		if !docxTextParts.MatchString(file.Name) {
+			// Copy the compressed bytes as they are
+			if err := output.Copy(file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		part, err := readZipFile(file, t.maxPartSize)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidDOCX, err)
+		}
+
+		translated, err := t.translatePart(ctx, part, sourceLang, targetLang)
+		if err != nil {
+			return fmt.Errorf("failed to translate %s: %w", file.Name, err)
+		}
+
+		header := file.FileHeader
+		entry, err := output.CreateHeader(&header)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(translated); err != nil {
+			return err
+		}
+	}
+
+	return output.Close()
+}
+
+// translatePart translates every paragraph of one WordprocessingML part
+func (t *DOCXTranslator) translatePart(ctx context.Context, part []byte, sourceLang, targetLang string) ([]byte, error) {
+	paragraphs := parseDOCXParagraphs(part)
+	if len(paragraphs) == 0 {
+		return part, nil
+	}
+
+	texts := make([]string, len(paragraphs))
+	marks := make([]*markedText, len(paragraphs))
+	protectable := make([]bool, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		marked := &markedText{}
+		var source strings.Builder
+		for j, segment := range paragraph.segments {
+			if j > 0 {
+				marked.AddProtected(segmentBoundary)
+			}
+			text := docxSegmentText(part, segment)
+			marked.AddText(text)
+			source.WriteString(text)
+		}
+		texts[i] = marked.String()
+		marks[i] = marked
+		protectable[i] = !hasPlaceholderSyntax(source.String())
+	}
+
+	translated, err := t.translationService.TranslateTexts(ctx, texts, sourceLang, targetLang)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out the new content of every text node
+	var nodes []docxText
+	replacements := make(map[int]string)
+	for i, paragraph := range paragraphs {
+		var parts []string
+		restored, ok := marks[i].Restore(translated[i])
+		if ok && protectable[i] {
+			parts = strings.Split(restored, segmentBoundary)
+		}
+		if len(parts) != len(paragraph.segments) {
+			// The boundaries were lost, so share the text out by original length
+			plain := placeholderPattern.ReplaceAllString(translated[i], "")
+			weights := make([]int, len(paragraph.segments))
+			for j, segment := range paragraph.segments {
+				weights[j] = utf8.RuneCountInString(docxSegmentText(part, segment))
+			}
+			parts = splitProportionally(plain, weights)
+		}
+
+		for j, segment := range paragraph.segments {
+			// The first node of a segment carries its text, the rest are emptied
+			for k, node := range segment {
+				nodes = append(nodes, node)
+				if k == 0 {
+					replacements[node.tagStart] = parts[j]
+				} else {
+					replacements[node.tagStart] = ""
+				}
+			}
+		}
+	}
+
+	// Text box paragraphs sit inside other paragraphs, so splice in document order
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].tagStart < nodes[j].tagStart })
+
+	var output bytes.Buffer
+	position := 0
+	for _, node := range nodes {
+		output.Write(part[position:node.tagStart])
+		output.WriteString(`<w:t xml:space="preserve">`)
+		xml.EscapeText(&output, []byte(replacements[node.tagStart]))
+		position = node.contentEnd
+	}
+	output.Write(part[position:])
+
+	return output.Bytes(), nil
+}
+
+// parseDOCXParagraphs finds the text nodes of every paragraph, in the order of
+// their closing tags. Paragraphs nested in text boxes are collected separately
+// from the paragraph that contains them.
+func parseDOCXParagraphs(part []byte) []docxParagraph {
+	type openParagraph struct {
+		nodes []docxText
+	}
+
+	var paragraphs []docxParagraph
+	var stack []*openParagraph
+	var runFormats []string
+	formatStart := -1
+	textStart, textContent := -1, -1
+
+	for _, loc := range docxTagPattern.FindAllSubmatchIndex(part, -1) {
+		closing := loc[3] > loc[2]
+		selfClosing := loc[7] > loc[6]
+		name := string(part[loc[4]:loc[5]])
+
+		switch {
+		case name == "w:p" && !closing && !selfClosing:
+			stack = append(stack, &openParagraph{})
+		case name == "w:p" && closing && len(stack) > 0:
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if paragraph, ok := groupDOCXSegments(part, current.nodes); ok {
+				paragraphs = append(paragraphs, paragraph)
+			}
+		case name == "w:r" && !closing && !selfClosing:
+			runFormats = append(runFormats, "")
+		case name == "w:r" && closing && len(runFormats) > 0:
+			runFormats = runFormats[:len(runFormats)-1]
+		case name == "w:rPr" && !closing && !selfClosing:
+			formatStart = loc[1]
+		case name == "w:rPr" && closing && formatStart >= 0 && len(runFormats) > 0:
+			runFormats[len(runFormats)-1] = docxRevisionAttrs.ReplaceAllString(string(part[formatStart:loc[0]]), "")
+			formatStart = -1
+		case name == "w:t" && !closing && !selfClosing:
+			textStart, textContent = loc[0], loc[1]
+		case name == "w:t" && closing && textStart >= 0 && len(stack) > 0:
+			format := ""
+			if len(runFormats) > 0 {
+				format = runFormats[len(runFormats)-1]
+			}
+			current := stack[len(stack)-1]
+			current.nodes = append(current.nodes, docxText{
+				tagStart:     textStart,
+				contentStart: textContent,
+				contentEnd:   loc[0],
+				format:       format,
+			})
+			textStart, textContent = -1, -1
+		}
+	}
+
+	return paragraphs
+}
+
+// groupDOCXSegments merges neighbouring text nodes that share formatting and
+// are separated only by run markup. It reports false for paragraphs without
+// any visible text.
+func groupDOCXSegments(part []byte, nodes []docxText) (docxParagraph, bool) {
+	var paragraph docxParagraph
+	hasText := false
+	for i, node := range nodes {
+		if strings.TrimSpace(string(part[node.contentStart:node.contentEnd])) != "" {
+			hasText = true
+		}
+
+		if i > 0 {
+			previous := nodes[i-1]
+			gap := part[previous.contentEnd:node.tagStart]
+			if previous.format == node.format && docxMergeableGap.Match(gap) {
+				last := len(paragraph.segments) - 1
+				paragraph.segments[last] = append(paragraph.segments[last], node)
+				continue
+			}
+		}
+		paragraph.segments = append(paragraph.segments, []docxText{node})
+	}
+	return paragraph, hasText
+}
+
+// docxSegmentText returns the unescaped text of a segment
+func docxSegmentText(part []byte, segment []docxText) string {
+	var text strings.Builder
+	for _, node := range segment {
+		text.WriteString(html.UnescapeString(string(part[node.contentStart:node.contentEnd])))
+	}
+	return text.String()
+}
+
+// splitProportionally cuts text into len(weights) pieces whose lengths follow
+// the weights, moving each cut forward to the next space so words stay whole
+func splitProportionally(text string, weights []int) []string {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	parts := make([]string, len(weights))
+	if total == 0 || len(weights) == 1 {
+		parts[0] = text
+		return parts
+	}
+
+	runes := []rune(text)
+	start, seen := 0, 0
+	for i := 0; i < len(weights)-1; i++ {
+		seen += weights[i]
+		cut := len(runes) * seen / total
+		for cut < len(runes) && cut > start && runes[cut-1] != ' ' {
+			cut++
+		}
+		if cut < start {
+			cut = start
+		}
+		parts[i] = string(runes[start:cut])
+		start = cut
+	}
+	parts[len(weights)-1] = string(runes[start:])
+	return parts
+}
+
+// readZipFile reads a whole zip entry of at most maxSize bytes. The size in
+// the header is checked first, but the read is capped as well since the
+// header may lie.
+func readZipFile(file *zip.File, maxSize int64) ([]byte, error) {
+	if file.UncompressedSize64 > uint64(maxSize) {
+		return nil, fmt.Errorf("%s is larger than %d bytes", file.Name, maxSize)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", file.Name, maxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// buildDOCX zips parts into a .docx
+func buildDOCX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		entry, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(entry, content)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readDOCXPart returns one part of a .docx
+func readDOCXPart(t *testing.T, docx []byte, name string) string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(docx), int64(len(docx)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range archive.File {
+		if file.Name == name {
+			part, err := readZipFile(file, defaultDOCXMaxPartSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(part)
+		}
+	}
+	t.Fatalf("%s is missing", name)
+	return ""
+}
+
+func TestDOCXTranslatorMergesSplitRuns(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	translator := NewDOCXTranslator(fake.service())
+
+	// Word split "Hello" across two runs of the same formatting
+	document := `<w:document><w:body><w:p>` +
+		`<w:r w:rsidR="001"><w:t>Hel</w:t></w:r><w:proofErr w:type="spellStart"/>` +
+		`<w:r w:rsidR="002"><w:t xml:space="preserve">lo </w:t></w:r>` +
+		`<w:r><w:rPr><w:b/></w:rPr><w:t>world</w:t></w:r>` +
+		`</w:p></w:body></w:document>`
+	input := buildDOCX(t, map[string]string{"word/document.xml": document, "word/styles.xml": "<w:styles/>"})
+
+	var output bytes.Buffer
+	if err := translator.Translate(context.Background(), bytes.NewReader(input), int64(len(input)), &output, "en", "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<w:document><w:body><w:p>` +
+		`<w:r w:rsidR="001"><w:t xml:space="preserve">HELLO </w:t></w:r><w:proofErr w:type="spellStart"/>` +
+		`<w:r w:rsidR="002"><w:t xml:space="preserve"></w:t></w:r>` +
+		`<w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">WORLD</w:t></w:r>` +
+		`</w:p></w:body></w:document>`
+	if got := readDOCXPart(t, output.Bytes(), "word/document.xml"); got != want {
+		t.Errorf("document.xml = %s, want %s", got, want)
+	}
+	if got := readDOCXPart(t, output.Bytes(), "word/styles.xml"); got != "<w:styles/>" {
+		t.Errorf("styles.xml = %s, want it copied unchanged", got)
+	}
+	if inputs := fake.computeInputs(); len(inputs) != 1 || len(inputs[0]) != 1 || !strings.HasPrefix(inputs[0][0], "Hello ") {
+		t.Errorf("compute inputs = %q, want the paragraph in one input", inputs)
+	}
+}
+
+func TestDOCXTranslatorRejectsLargeParts(t *testing.T) {
+	t.Setenv("DOCX_MAX_PART_SIZE", "1024")
+	fake := newFakeBhashini(t, upperCase)
+	translator := NewDOCXTranslator(fake.service())
+
+	document := `<w:document><w:body><w:p><w:r><w:t>` + strings.Repeat("a", 2048) + `</w:t></w:r></w:p></w:body></w:document>`
+	input := buildDOCX(t, map[string]string{"word/document.xml": document})
+
+	err := translator.Translate(context.Background(), bytes.NewReader(input), int64(len(input)), io.Discard, "en", "hi")
+	if !errors.Is(err, ErrInvalidDOCX) {
+		t.Errorf("Translate() = %v, want ErrInvalidDOCX", err)
+	}
+	if len(fake.computeInputs()) != 0 {
+		t.Error("Translate() called compute for a rejected document")
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResult is the answer of a fake database to one statement: the rows of
+// a query, or the error it fails with
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error
+}
+
+// fakeDB is a database/sql driver that answers every statement with handle,
+// for testing code that talks to Postgres without a server. Statements are
+// matched on their text, so handlers look for the table or clause they
+// answer for.
+type fakeDB struct {
+	mu     sync.Mutex
+	handle func(query string, args []driver.Value) fakeResult
+}
+
+// openFakeDB opens a database answered by handle
+func openFakeDB(t *testing.T, handle func(query string, args []driver.Value) fakeResult) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(&fakeDB{handle: handle})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (d *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: d}, nil }
+func (d *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+// fakeDriver only exists to satisfy driver.Connector
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return nil, driver.ErrSkip }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) run(args []driver.Value) fakeResult {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	return s.db.handle(strings.Join(strings.Fields(s.query), " "), args)
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result := s.run(args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(len(result.rows)), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	result := s.run(args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
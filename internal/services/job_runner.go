@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"user-service/internal/logging"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// jobItemsPerRound is how many items a worker loads and saves at a time. A
+// restarted job loses at most one round of work.
+const jobItemsPerRound = 100
+
+// errJobReleased is returned when a job stops belonging to the worker that is
+// running it, because it was cancelled or reclaimed after a missed heartbeat
+var errJobReleased = errors.New("job was cancelled or reclaimed")
+
+// errRunnerStopped is returned when a job is interrupted because its runner
+// is shutting down
+var errRunnerStopped = errors.New("job runner stopped")
+
+// JobRunner runs queued translation jobs with a pool of in-process workers.
+// Jobs are claimed from Postgres, so any number of replicas can share a queue
+// and a job interrupted by a restart is picked up again by the next worker.
+type JobRunner struct {
+	db           *sql.DB
+	jobRepo      *repository.JobRepository
+	keyRepo      *repository.APIKeyRepository
+	instanceID   string
+	workers      int
+	pollInterval time.Duration
+	lockTimeout  time.Duration
+	maxAttempts  int
+	resultTTL    time.Duration
+	ctx          context.Context // cancelled by Stop, interrupting running jobs
+	cancel       context.CancelCauseFunc
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewJobRunner creates a job runner configured from the environment
+func NewJobRunner(db *sql.DB) *JobRunner {
+	// Parse worker count from env (default 2)
+	workers := 2
+	if workersStr := os.Getenv("JOB_WORKERS"); workersStr != "" {
+		if parsed, err := strconv.Atoi(workersStr); err == nil && parsed >= 0 {
+			workers = parsed
+		}
+	}
+
+	// Parse max attempts from env (default 5)
+	maxAttempts := 5
+	if attemptsStr := os.Getenv("JOB_MAX_ATTEMPTS"); attemptsStr != "" {
+		if parsed, err := strconv.Atoi(attemptsStr); err == nil && parsed > 0 {
+			maxAttempts = parsed
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	return &JobRunner{
+		db:           db,
+		jobRepo:      repository.NewJobRepository(db),
+		keyRepo:      repository.NewAPIKeyRepository(db),
+		instanceID:   uuid.New().String()[:8],
+		workers:      workers,
+		pollInterval: durationFromEnv("JOB_POLL_INTERVAL", 2*time.Second),
+		lockTimeout:  durationFromEnv("JOB_LOCK_TIMEOUT", 2*time.Minute),
+		maxAttempts:  maxAttempts,
+		resultTTL:    JobResultTTL(),
+		ctx:          ctx,
+		cancel:       cancel,
+		stop:         make(chan struct{}),
+	}
+}
+
+// JobResultTTL returns how long finished jobs and their results are kept
+// (JOB_RESULT_TTL, default 7 days)
+func JobResultTTL() time.Duration {
+	return durationFromEnv("JOB_RESULT_TTL", 7*24*time.Hour)
+}
+
+// durationFromEnv parses a Go duration from env, falling back to def
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return def
+}
+
+// Start launches the workers and the cleanup of expired jobs
+func (r *JobRunner) Start() {
+	for i := 0; i < r.workers; i++ {
+		workerID := fmt.Sprintf("%s-%d", r.instanceID, i)
+		r.wg.Add(1)
+		go r.work(workerID)
+	}
+
+	r.wg.Add(1)
+	go r.cleanExpired()
+}
+
+// Stop stops the workers, handing their current jobs back to the queue. Jobs
+// resume from their first unfinished item, so only the round in progress is
+// lost.
+func (r *JobRunner) Stop() {
+	r.cancel(errRunnerStopped)
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// work claims and runs jobs until the runner is stopped
+func (r *JobRunner) work(workerID string) {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		job, err := r.jobRepo.ClaimJob(context.Background(), workerID, r.lockTimeout)
+		if err != nil {
+			slog.Error("Job claim failed", "worker_id", workerID, "error", err)
+		}
+		if job == nil {
+			select {
+			case <-r.stop:
+				return
+			case <-time.After(r.pollInterval):
+			}
+			continue
+		}
+
+		r.runJob(job, workerID)
+	}
+}
+
+// runJob runs a claimed job to completion, or hands it back to the queue
+func (r *JobRunner) runJob(job *models.TranslationJob, workerID string) {
+	// Each attempt at a job is traced on its own, and logged and sent upstream
+	// with the job ID as its request ID. It is cancelled as soon as the job
+	// stops belonging to this worker, or when the runner stops.
+	ctx, release := context.WithCancelCause(logging.WithRequestID(r.ctx, job.ID))
+	defer release(nil)
+	ctx, span := tracing.Start(ctx, "JobRunner.runJob", trace.WithAttributes(
+		attribute.String("job.id", job.ID),
+		attribute.String("job.type", job.Type),
+		attribute.Int("job.attempt", job.Attempts),
+	))
+
+	// Keep the lock fresh while long upstream calls run, and notice cancellation
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(r.lockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if owned, err := r.jobRepo.Heartbeat(ctx, job.ID, workerID); err == nil && !owned {
+					release(errJobReleased)
+					return
+				}
+			}
+		}
+	}()
+
+	// Each attempt has its own service, with a fresh retry budget
+	bhashiniClient := NewBhashiniClient()
+	cacheRepo := repository.NewTranslationRepository(r.db)
+	translationService := NewTranslationService(bhashiniClient, cacheRepo)
+
+	// Attribute the job to the key that created it, and charge its quota
+	caller := Caller{APIKeyID: job.APIKeyID}
+	if job.APIKeyID != "" {
+		if key, err := r.keyRepo.GetKey(ctx, job.APIKeyID); err == nil {
+			caller.Quota = NewCharQuota(r.db, key)
+		} else if !errors.Is(err, repository.ErrAPIKeyNotFound) {
+			slog.Error("Job API key lookup failed", "job_id", job.ID, "error", err)
+		}
+	}
+	translationService.SetCaller(caller)
+
+	var err error
+	switch job.Type {
+	case models.JobTypeDocument:
+		err = r.runDocumentJob(ctx, job, workerID, translationService)
+	default:
+		err = r.runBatchJob(ctx, job, workerID, translationService)
+	}
+	err = attemptError(ctx, err)
+	tracing.End(span, err)
+
+	// The outcome is recorded even when the attempt's context was cancelled
+	ctx = context.WithoutCancel(ctx)
+	action, delay, countAttempt := nextJobAction(job, err, r.maxAttempts)
+	switch action {
+	case jobComplete:
+		r.finishJob(ctx, job.ID, workerID, models.JobStatusCompleted, "")
+	case jobFail:
+		r.finishJob(ctx, job.ID, workerID, models.JobStatusFailed, err.Error())
+	case jobRetry:
+		if err := r.jobRepo.RetryJob(ctx, job.ID, workerID, err.Error(), delay, countAttempt); err != nil {
+			slog.Error("Job retry failed", "job_id", job.ID, "error", err)
+		}
+	case jobRelease:
+		if err := r.jobRepo.ReleaseJob(ctx, job.ID, workerID); err != nil {
+			slog.Error("Job release failed", "job_id", job.ID, "error", err)
+		}
+	}
+}
+
+// attemptError returns the error an attempt at a job ended with. Calls cut
+// short by a release or by Stop fail with context.Canceled, so the cause is
+// returned for them instead; an attempt that finished before it stays a
+// success.
+func attemptError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, errJobReleased) || errors.Is(cause, errRunnerStopped) {
+		return cause
+	}
+	return err
+}
+
+// jobAction is what becomes of a job after an attempt at it
+type jobAction int
+
+const (
+	jobComplete jobAction = iota // mark it completed
+	jobFail                      // mark it failed
+	jobRetry                     // queue it again after a delay
+	jobRelease                   // hand it back to the queue at once, without counting the attempt
+	jobAbandon                   // leave it to whoever owns it now
+)
+
+// nextJobAction decides what becomes of a job after an attempt that ended
+// with err, and for retries how long to wait and whether the attempt counts
+// towards maxAttempts
+func nextJobAction(job *models.TranslationJob, err error, maxAttempts int) (jobAction, time.Duration, bool) {
+	var quotaErr *QuotaExceededError
+	var quotaTooSmallErr *QuotaTooSmallError
+	var circuitErr *CircuitOpenError
+	switch {
+	case err == nil:
+		return jobComplete, 0, false
+	case errors.Is(err, errJobReleased):
+		// Cancelled or taken over; whoever owns the job now decides its fate
+		return jobAbandon, 0, false
+	case errors.Is(err, errRunnerStopped):
+		// Shutting down; the next worker to claim the job picks it up where it stopped
+		return jobRelease, 0, false
+	case errors.As(err, &quotaErr):
+		// Waiting for the quota to reset is not a failed attempt, so it never
+		// uses up maxAttempts
+		return jobRetry, quotaErr.RetryAfter, false
+	case errors.As(err, &circuitErr):
+		// Nor is waiting for Bhashini's circuit to close
+		return jobRetry, circuitErr.RetryAfter, false
+	case errors.Is(err, ErrNoSubtitleCues) || errors.As(err, &quotaTooSmallErr) || job.Attempts >= maxAttempts:
+		return jobFail, 0, false
+	}
+
+	// Back off 10s, 20s, 40s... up to 10 minutes before the next attempt
+	delay := 10 * time.Minute
+	if job.Attempts < 7 {
+		delay = 10 * time.Second << (job.Attempts - 1)
+	}
+	return jobRetry, delay, true
+}
+
+// finishJob marks a job completed or failed and queues its webhook along with
+// the status, so a finished job always has its callback sent
+func (r *JobRunner) finishJob(ctx context.Context, jobID, workerID, status, errorMessage string) {
+	if _, err := r.jobRepo.FinishJob(ctx, jobID, workerID, status, errorMessage, r.resultTTL, jobWebhook); err != nil {
+		slog.Error("Job finish failed", "job_id", jobID, "error", err)
+	}
+}
+
+// runBatchJob translates the unfinished items of a batch job round by round,
+// saving each round before starting the next
+func (r *JobRunner) runBatchJob(ctx context.Context, job *models.TranslationJob, workerID string, translationService *TranslationService) error {
+	for {
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+
+		items, err := r.jobRepo.PendingItems(ctx, job.ID, jobItemsPerRound)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		// Each round gets the retry budget of one request, so long jobs
+		// survive transient upstream failures spread over their run
+		translationService.bhashiniClient.resetRetryBudget()
+
+		// Items of the same language pair go upstream together
+		groups := make(map[[2]string][]int)
+		var order [][2]string
+		for i, item := range items {
+			pair := [2]string{item.SourceLang, item.TargetLang}
+			if _, ok := groups[pair]; !ok {
+				order = append(order, pair)
+			}
+			groups[pair] = append(groups[pair], i)
+		}
+
+		for _, pair := range order {
+			texts := make([]string, len(groups[pair]))
+			for j, i := range groups[pair] {
+				texts[j] = items[i].SourceText
+			}
+
+			translated, err := translationService.TranslateTexts(ctx, texts, pair[0], pair[1])
+			if err != nil {
+				return err
+			}
+			for j, i := range groups[pair] {
+				items[i].TranslatedText = translated[j]
+			}
+		}
+
+		owned, err := r.jobRepo.CompleteItems(ctx, job.ID, workerID, items)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return errJobReleased
+		}
+	}
+}
+
+// runDocumentJob translates the single document of a document job
+func (r *JobRunner) runDocumentJob(ctx context.Context, job *models.TranslationJob, workerID string, translationService *TranslationService) error {
+	items, err := r.jobRepo.PendingItems(ctx, job.ID, 1)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	item := items[0]
+
+	switch job.DocumentFormat {
+	case models.DocumentFormatMarkdown:
+		item.TranslatedText, err = NewMarkdownTranslator(translationService).Translate(ctx, item.SourceText, item.SourceLang, item.TargetLang, DefaultFrontMatterKeys)
+	case models.DocumentFormatSubtitles:
+		item.TranslatedText, err = NewSubtitleTranslator(translationService).Translate(ctx, item.SourceText, item.SourceLang, item.TargetLang)
+	default:
+		item.TranslatedText, err = translationService.Translate(ctx, item.SourceText, item.SourceLang, item.TargetLang)
+	}
+	if err != nil {
+		return err
+	}
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+
+	owned, err := r.jobRepo.CompleteItems(ctx, job.ID, workerID, []models.TranslationJobItem{item})
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return errJobReleased
+	}
+	return nil
+}
+
+// cleanExpired deletes jobs whose results are past their retention period
+func (r *JobRunner) cleanExpired() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.jobRepo.DeleteExpiredJobs(context.Background()); err != nil {
+				slog.Error("Job cleanup failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"user-service/internal/models"
+)
+
+func TestNextJobAction(t *testing.T) {
+	failure := errors.New("API returned status 502")
+	tests := []struct {
+		name         string
+		attempts     int
+		err          error
+		action       jobAction
+		delay        time.Duration
+		countAttempt bool
+	}{
+		{"success", 1, nil, jobComplete, 0, false},
+		{"released", 1, errJobReleased, jobAbandon, 0, false},
+		{"runner stopped", 5, errRunnerStopped, jobRelease, 0, false},
+		{"first failure", 1, failure, jobRetry, 10 * time.Second, true},
+		{"third failure", 3, failure, jobRetry, 40 * time.Second, true},
+		{"last attempt", 5, failure, jobFail, 0, false},
+		{"wrapped failure on the last attempt", 5, fmt.Errorf("compute: %w", failure), jobFail, 0, false},
+		{"no subtitle cues", 1, fmt.Errorf("document: %w", ErrNoSubtitleCues), jobFail, 0, false},
+		{"quota used up", 5, &QuotaExceededError{Limit: 100, Used: 90, Period: "day", RetryAfter: time.Hour}, jobRetry, time.Hour, false},
+		{"quota too small", 1, &QuotaTooSmallError{Limit: 100, Characters: 500, Period: "day"}, jobFail, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &models.TranslationJob{ID: "job", Attempts: tt.attempts}
+			action, delay, countAttempt := nextJobAction(job, tt.err, 5)
+			if action != tt.action || delay != tt.delay || countAttempt != tt.countAttempt {
+				t.Errorf("nextJobAction() = %v, %s, %v, want %v, %s, %v",
+					action, delay, countAttempt, tt.action, tt.delay, tt.countAttempt)
+			}
+		})
+	}
+}
+
+func TestNextJobActionBackoffCap(t *testing.T) {
+	job := &models.TranslationJob{ID: "job", Attempts: 9}
+	if action, delay, _ := nextJobAction(job, errors.New("timeout"), 20); action != jobRetry || delay != 10*time.Minute {
+		t.Errorf("nextJobAction() = %v, %s, want a retry after 10m", action, delay)
+	}
+}
+
+func TestAttemptError(t *testing.T) {
+	failure := errors.New("API returned status 502")
+	tests := []struct {
+		name  string
+		cause error
+		err   error
+		want  error
+	}{
+		{"not cancelled", nil, failure, failure},
+		{"released", errJobReleased, context.Canceled, errJobReleased},
+		{"stopped", errRunnerStopped, context.Canceled, errRunnerStopped},
+		{"finished before stop", errRunnerStopped, nil, nil},
+		{"finished before release", errJobReleased, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			if tt.cause != nil {
+				cancel(tt.cause)
+			}
+			if got := attemptError(ctx, tt.err); !errors.Is(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("attemptError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
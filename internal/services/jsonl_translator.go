@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Limits of JSONL translation. Only one batch of records is held in memory at
+// a time; longer lines are reported as errors and skipped.
+const (
+	jsonlBatchRecords = 100
+	jsonlMaxLineBytes = 1 << 20
+)
+
+// JSONLRecord is one input line of a JSONL translation. ID is kept as raw
+// JSON so string and numeric IDs come back exactly as they were sent.
+type JSONLRecord struct {
+	ID         json.RawMessage `json:"id"`
+	SourceText string          `json:"source_text"`
+	SourceLang string          `json:"source_lang"`
+	TargetLang string          `json:"target_lang"`
+}
+
+// JSONLResult is one output line of a JSONL translation
+type JSONLResult struct {
+	ID             json.RawMessage `json:"id"`
+	Line           int             `json:"line"`
+	SourceLang     string          `json:"source_lang,omitempty"`
+	TargetLang     string          `json:"target_lang,omitempty"`
+	TranslatedText string          `json:"translated_text,omitempty"`
+	Stale          bool            `json:"stale,omitempty"`
+	Error          string          `json:"error,omitempty"`
+}
+
+// JSONLStats counts the records of a JSONL translation
+type JSONLStats struct {
+	Records int
+	Failed  int
+}
+
+// JSONLTranslator translates JSONL streams of JSONLRecord lines into JSONL
+// streams of JSONLResult lines, one per non-blank input line and in input
+// order. Problems with a single line are reported on that line's result,
+// with its line number, instead of failing the whole stream.
+type JSONLTranslator struct {
+	translationService *TranslationService
+}
+
+// NewJSONLTranslator creates a new JSONL translator
+func NewJSONLTranslator(translationService *TranslationService) *JSONLTranslator {
+	return &JSONLTranslator{translationService: translationService}
+}
+
+// Translate reads records from r and writes results to w. Records are
+// processed in batches as they are read; the records of a batch that share a
+// language pair go upstream together. When w can be flushed, as a
+// bufio.Writer can, it is flushed after every batch.
+func (t *JSONLTranslator) Translate(ctx context.Context, r io.Reader, w io.Writer) (JSONLStats, error) {
+	var stats JSONLStats
+	reader := bufio.NewReaderSize(r, 64<<10)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	batch := make([]JSONLResult, 0, jsonlBatchRecords)
+	texts := make([]string, 0, jsonlBatchRecords)
+	flush := func() error {
+		// Stop at the deadline rather than failing every remaining record
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t.translateBatch(ctx, batch, texts)
+		for _, result := range batch {
+			stats.Records++
+			if result.Error != "" {
+				stats.Failed++
+			}
+			if err := encoder.Encode(result); err != nil {
+				return err
+			}
+		}
+		batch, texts = batch[:0], texts[:0]
+		if flusher, ok := w.(interface{ Flush() error }); ok {
+			return flusher.Flush()
+		}
+		return nil
+	}
+
+	lineNumber := 0
+	for {
+		line, tooLong, err := readJSONLLine(reader)
+		if err != nil && err != io.EOF {
+			return stats, err
+		}
+		if err == nil || len(line) > 0 || tooLong {
+			lineNumber++
+		}
+
+		if tooLong {
+			batch = append(batch, JSONLResult{ID: json.RawMessage("null"), Line: lineNumber, Error: "line is longer than 1 MiB"})
+			texts = append(texts, "")
+		} else if len(line) > 0 {
+			result, text := parseJSONLRecord(line, lineNumber)
+			batch = append(batch, result)
+			texts = append(texts, text)
+		}
+
+		if len(batch) == jsonlBatchRecords || (err == io.EOF && len(batch) > 0) {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+		if err == io.EOF {
+			return stats, nil
+		}
+	}
+}
+
+// translateBatch fills in the translations of a batch's valid records. If a
+// language pair fails as a whole because of its input, its records are
+// retried one by one so a single bad text does not fail its neighbours.
+func (t *JSONLTranslator) translateBatch(ctx context.Context, batch []JSONLResult, texts []string) {
+	groups := make(map[[2]string][]int)
+	var order [][2]string
+	for i, result := range batch {
+		if result.Error != "" {
+			continue
+		}
+		pair := [2]string{result.SourceLang, result.TargetLang}
+		if _, ok := groups[pair]; !ok {
+			order = append(order, pair)
+		}
+		groups[pair] = append(groups[pair], i)
+	}
+
+	for _, pair := range order {
+		groupTexts := make([]string, len(groups[pair]))
+		for j, i := range groups[pair] {
+			groupTexts[j] = texts[i]
+		}
+
+		// Expired cache entries are only counted per call, so a pair that used
+		// any marks all of its records
+		stale := t.translationService.StaleSentences()
+		translated, err := t.translationService.TranslateTexts(ctx, groupTexts, pair[0], pair[1])
+		if err == nil {
+			for j, i := range groups[pair] {
+				batch[i].TranslatedText = translated[j]
+				batch[i].Stale = t.translationService.StaleSentences() > stale
+			}
+			continue
+		}
+		if !inputError(ctx, err) {
+			for _, i := range groups[pair] {
+				batch[i].Error = err.Error()
+			}
+			continue
+		}
+
+		for _, i := range groups[pair] {
+			stale := t.translationService.StaleSentences()
+			translatedText, err := t.translationService.Translate(ctx, texts[i], pair[0], pair[1])
+			if err != nil {
+				batch[i].Error = err.Error()
+				continue
+			}
+			batch[i].TranslatedText = translatedText
+			batch[i].Stale = t.translationService.StaleSentences() > stale
+		}
+	}
+}
+
+// inputError reports whether a failed translation may be down to the texts
+// sent, rather than to the quota, the circuit breaker or the request's context,
+// which would fail every record alike
+func inputError(ctx context.Context, err error) bool {
+	var quotaErr *QuotaExceededError
+	var circuitErr *CircuitOpenError
+	switch {
+	case ctx.Err() != nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &quotaErr), errors.As(err, &circuitErr):
+		return false
+	}
+	return true
+}
+
+// parseJSONLRecord validates one input line, returning its result with the
+// error already set if the line is not a usable record
+func parseJSONLRecord(line []byte, lineNumber int) (JSONLResult, string) {
+	result := JSONLResult{ID: json.RawMessage("null"), Line: lineNumber}
+
+	var record JSONLRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		result.Error = "invalid JSON: " + err.Error()
+		return result, ""
+	}
+	if len(record.ID) > 0 {
+		result.ID = record.ID
+	}
+	if record.SourceText == "" || record.SourceLang == "" || record.TargetLang == "" {
+		result.Error = "source_text, source_lang, and target_lang are required"
+	} else {
+		result.Error = upstreamLanguages.ResolvePair(&record.SourceLang, &record.TargetLang)
+	}
+	result.SourceLang = record.SourceLang
+	result.TargetLang = record.TargetLang
+	return result, record.SourceText
+}
+
+// readJSONLLine reads the next line without its line ending. Lines longer than
+// jsonlMaxLineBytes are discarded and reported as too long.
+func readJSONLLine(reader *bufio.Reader) ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > jsonlMaxLineBytes+2 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return bytes.TrimSpace(line), tooLong, err
+	}
+}
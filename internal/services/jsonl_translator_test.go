@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLTranslator(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	translator := NewJSONLTranslator(fake.service())
+
+	input := `{"id": "a", "source_text": "Hello", "source_lang": "en", "target_lang": "hi"}` + "\n" +
+		"\n" +
+		`{"id": 7, "source_text": "World", "source_lang": "en", "target_lang": "xx"}` + "\n" +
+		`not json` + "\n" +
+		`{"source_text": "Bye", "source_lang": "en-IN", "target_lang": "hin"}`
+	var output bytes.Buffer
+	stats, err := translator.Translate(context.Background(), strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	want := []string{
+		`{"id":"a","line":1,"source_lang":"en","target_lang":"hi","translated_text":"HELLO"}`,
+		`{"id":7,"line":3,"source_lang":"en","target_lang":"xx","error":"target_lang 'xx' is not supported"}`,
+		`{"id":null,"line":4,"error":"invalid JSON: invalid character 'o' in literal null (expecting 'u')"}`,
+		`{"id":null,"line":5,"source_lang":"en","target_lang":"hi","translated_text":"BYE"}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	if stats.Records != 4 || stats.Failed != 2 {
+		t.Errorf("stats = %+v, want 4 records, 2 failed", stats)
+	}
+	if inputs := fake.computeInputs(); len(inputs) != 1 {
+		t.Errorf("compute called %d times, want the pair's records sent together", len(inputs))
+	}
+}
+
+func TestJSONLTranslatorFallsBackPerRecord(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	fake.failWith(http.StatusBadRequest)
+	translator := NewJSONLTranslator(fake.service())
+
+	input := `{"id": 1, "source_text": "One", "source_lang": "en", "target_lang": "hi"}` + "\n" +
+		`{"id": 2, "source_text": "Two", "source_lang": "en", "target_lang": "hi"}` + "\n"
+	stats, err := translator.Translate(context.Background(), strings.NewReader(input), &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Failed != 2 {
+		t.Errorf("stats = %+v, want both records failed", stats)
+	}
+	if inputs := fake.computeInputs(); len(inputs) != 3 {
+		t.Errorf("compute called %d times, want once for the pair and once per record", len(inputs))
+	}
+}
+
+func TestInputError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"upstream rejected the input", context.Background(), errors.New("API returned status 400"), true},
+		{"quota too small for the pair", context.Background(), &QuotaTooSmallError{Limit: 10, Characters: 20, Period: "day"}, true},
+		{"quota used up", context.Background(), fmt.Errorf("translate: %w", &QuotaExceededError{Limit: 10, Used: 10, Period: "day", RetryAfter: time.Hour}), false},
+		{"circuit open", context.Background(), &CircuitOpenError{Endpoint: "compute", RetryAfter: time.Second}, false},
+		{"deadline", context.Background(), fmt.Errorf("compute: %w", context.DeadlineExceeded), false},
+		{"request cancelled", cancelled, errors.New("API call failed"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inputError(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("inputError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"user-service/internal/constants"
+	"user-service/internal/models"
+	"user-service/internal/tracing"
+)
+
+// Language discovery defaults, overridden by LANGUAGE_DISCOVERY_INTERVAL
+const (
+	defaultLanguageDiscoveryInterval = 6 * time.Hour
+	languageDiscoveryRetry           = time.Minute      // wait before retrying a failed discovery
+	languageDiscoveryTimeout         = 30 * time.Second // longest one discovery may take
+)
+
+// LanguagePairInfo is a language pair a Bhashini pipeline translates, as its
+// pipeline config describes it
+type LanguagePairInfo struct {
+	SourceLang   string `json:"source_lang"`
+	SourceScript string `json:"source_script,omitempty"`
+	TargetLang   string `json:"target_lang"`
+	TargetScript string `json:"target_script,omitempty"`
+	PipelineID   string `json:"pipeline_id,omitempty"`
+	ServiceID    string `json:"service_id,omitempty"`
+	ModelID      string `json:"model_id,omitempty"`
+}
+
+// LanguageCatalog holds the language pairs the routed pipelines translate,
+// discovered from their pipeline configs. Languages are identified by their
+// canonical tags, such as "hi" or "mni-Beng". Until a discovery succeeds it
+// knows no pairs, and the languages of constants.Languages are accepted in any
+// combination instead. It is safe for concurrent use.
+type LanguageCatalog struct {
+	mu          sync.RWMutex
+	pairs       []LanguagePairInfo
+	supported   map[[2]string]bool
+	languages   map[string]bool
+	refreshedAt time.Time
+}
+
+// upstreamLanguages is the process's catalog, filled by LanguageDiscovery
+var upstreamLanguages = &LanguageCatalog{}
+
+// UpstreamLanguages returns the catalog requests are validated against
+func UpstreamLanguages() *LanguageCatalog {
+	return upstreamLanguages
+}
+
+// Discovered reports whether the catalog holds discovered pairs, and when
+// they were last refreshed
+func (c *LanguageCatalog) Discovered() (bool, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.supported != nil, c.refreshedAt
+}
+
+// HasLanguage reports whether any pair translates from or to a language
+func (c *LanguageCatalog) HasLanguage(code string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.supported == nil {
+		return constants.IsValidLanguage(code)
+	}
+	return c.languages[code]
+}
+
+// Supports reports whether a pipeline translates from sourceLang to targetLang
+func (c *LanguageCatalog) Supports(sourceLang, targetLang string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.supported == nil {
+		return constants.IsValidLanguage(sourceLang) && constants.IsValidLanguage(targetLang)
+	}
+	return c.supported[[2]string{sourceLang, targetLang}]
+}
+
+// ResolvePair normalises a language pair given as codes or BCP-47 tags, such
+// as "hi-IN" or "sat-Olck", to canonical tags in place, and checks that a
+// pipeline translates it. It returns an error message, leaving the pair
+// unchanged, or an empty string.
+func (c *LanguageCatalog) ResolvePair(sourceLang, targetLang *string) string {
+	source, target := canonicalLanguage(*sourceLang), canonicalLanguage(*targetLang)
+	switch {
+	case !c.HasLanguage(source):
+		return "source_lang '" + *sourceLang + "' is not supported"
+	case !c.HasLanguage(target):
+		return "target_lang '" + *targetLang + "' is not supported"
+	case !c.Supports(source, target):
+		return "translation from '" + *sourceLang + "' to '" + *targetLang + "' is not supported"
+	}
+	*sourceLang, *targetLang = source, target
+	return ""
+}
+
+// canonicalLanguage returns the canonical tag of a language code or BCP-47
+// tag. Tags the registry does not know are returned as they are, in case a
+// pipeline lists them.
+func canonicalLanguage(tag string) string {
+	if canonical, ok := constants.NormalizeLanguage(tag); ok {
+		return canonical
+	}
+	return tag
+}
+
+// Languages returns the codes of every language translated from or to, sorted
+func (c *LanguageCatalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.supported == nil {
+		return constants.LanguageCodes()
+	}
+	languages := make([]string, 0, len(c.languages))
+	for code := range c.languages {
+		languages = append(languages, code)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Pairs returns every discovered pair, by source and target language and then
+// in pipeline order. Until a discovery succeeds it returns every combination
+// of constants.Languages in their default scripts, without pipeline details.
+func (c *LanguageCatalog) Pairs() []LanguagePairInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.supported != nil {
+		return append([]LanguagePairInfo(nil), c.pairs...)
+	}
+
+	languages := append([]constants.Language(nil), constants.Languages...)
+	sort.Slice(languages, func(i, j int) bool { return languages[i].Code < languages[j].Code })
+	var pairs []LanguagePairInfo
+	for _, source := range languages {
+		for _, target := range languages {
+			if source.Code != target.Code {
+				pairs = append(pairs, LanguagePairInfo{
+					SourceLang:   source.Code,
+					SourceScript: source.Script(),
+					TargetLang:   target.Code,
+					TargetScript: target.Script(),
+				})
+			}
+		}
+	}
+	return pairs
+}
+
+// Refresh fetches the config of every routed pipeline and replaces the
+// catalog's pairs with the ones they list. Pipelines whose config cannot be
+// fetched keep the pairs found for them before; if no config can be fetched
+// the catalog is left as it was.
+func (c *LanguageCatalog) Refresh(ctx context.Context, client *BhashiniClient, pipelineIDs []string) (err error) {
+	ctx, span := tracing.Start(ctx, "LanguageCatalog.Refresh")
+	defer func() { tracing.End(span, err) }()
+
+	var pairs []LanguagePairInfo
+	var errs []error
+	fetched := make(map[string]bool)
+	for _, pipelineID := range pipelineIDs {
+		config, err := client.GetPipelineConfig(ctx, pipelineID, "", "")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pipeline %s: %w", pipelineID, err))
+			continue
+		}
+		fetched[pipelineID] = true
+		pairs = append(pairs, translationPairs(config)...)
+	}
+	if len(fetched) == 0 {
+		return errors.Join(errs...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, pair := range c.pairs {
+		if !fetched[pair.PipelineID] {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	// Order pairs by language, keeping the pipeline order within a pair
+	order := make(map[string]int, len(pipelineIDs))
+	for i, pipelineID := range pipelineIDs {
+		order[pipelineID] = i
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if a.SourceLang != b.SourceLang {
+			return a.SourceLang < b.SourceLang
+		}
+		if a.TargetLang != b.TargetLang {
+			return a.TargetLang < b.TargetLang
+		}
+		return order[a.PipelineID] < order[b.PipelineID]
+	})
+
+	c.pairs = pairs
+	c.supported = make(map[[2]string]bool, len(pairs))
+	c.languages = make(map[string]bool)
+	for _, pair := range pairs {
+		c.supported[[2]string{pair.SourceLang, pair.TargetLang}] = true
+		c.languages[pair.SourceLang] = true
+		c.languages[pair.TargetLang] = true
+	}
+	c.refreshedAt = time.Now()
+	return errors.Join(errs...)
+}
+
+// translationPairs lists the language pairs of a pipeline config's
+// translation task
+func translationPairs(config *models.PipelineConfigResponse) []LanguagePairInfo {
+	var pairs []LanguagePairInfo
+	for _, task := range config.PipelineResponseConfig {
+		if task.TaskType != "translation" {
+			continue
+		}
+		for _, item := range task.Config {
+			if item.Language.SourceLanguage == "" || item.Language.TargetLanguage == "" {
+				continue
+			}
+			sourceLang, sourceScript := pairLanguage(item.Language.SourceLanguage, item.Language.SourceScriptCode)
+			targetLang, targetScript := pairLanguage(item.Language.TargetLanguage, item.Language.TargetScriptCode)
+			pairs = append(pairs, LanguagePairInfo{
+				SourceLang:   sourceLang,
+				SourceScript: sourceScript,
+				TargetLang:   targetLang,
+				TargetScript: targetScript,
+				PipelineID:   config.PipelineID,
+				ServiceID:    item.ServiceID,
+				ModelID:      item.ModelID,
+			})
+		}
+	}
+	return pairs
+}
+
+// pairLanguage returns the canonical tag and script of a language listed in a
+// pipeline config, taking the registry's default when no script is listed
+func pairLanguage(code, script string) (string, string) {
+	language, defaultScript, ok := constants.ParseLanguage(code)
+	switch {
+	case !ok && script == "":
+		return code, ""
+	case !ok:
+		return code + "-" + script, script
+	case script == "":
+		script = defaultScript
+	}
+	if !language.HasScript(script) {
+		return language.Code + "-" + script, script
+	}
+	return language.Tag(script), script
+}
+
+// LanguageDiscovery refreshes the language catalog from the routed pipelines
+// at startup and then on a schedule
+type LanguageDiscovery struct {
+	catalog  *LanguageCatalog
+	router   *PipelineRouter
+	interval time.Duration
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewLanguageDiscovery creates the discovery of the process's catalog. It
+// runs every LANGUAGE_DISCOVERY_INTERVAL (default 6h); 0 disables it, leaving
+// the static language list in use.
+func NewLanguageDiscovery() *LanguageDiscovery {
+	interval := defaultLanguageDiscoveryInterval
+	if value := os.Getenv("LANGUAGE_DISCOVERY_INTERVAL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed >= 0 {
+			interval = parsed
+		}
+	}
+	return &LanguageDiscovery{
+		catalog:  upstreamLanguages,
+		router:   upstreamPipelineRouter(),
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start launches discovery in the background; requests are served with the
+// static language list until it first succeeds
+func (d *LanguageDiscovery) Start() {
+	if d.interval == 0 {
+		slog.Info("Language discovery disabled, using the static language list")
+		return
+	}
+	d.wg.Add(1)
+	go d.run()
+}
+
+// Stop stops discovery and waits for a refresh in progress
+func (d *LanguageDiscovery) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// run refreshes the catalog every interval, retrying sooner after a failure
+func (d *LanguageDiscovery) run() {
+	defer d.wg.Done()
+	for {
+		wait := d.interval
+		if err := d.refresh(); err != nil && languageDiscoveryRetry < wait {
+			wait = languageDiscoveryRetry
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// refresh runs one discovery with a fresh client
+func (d *LanguageDiscovery) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), languageDiscoveryTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := d.catalog.Refresh(ctx, NewBhashiniClient(), d.router.Pipelines())
+	if discovered, _ := d.catalog.Discovered(); discovered {
+		slog.Info("Discovered Bhashini languages", "languages", len(d.catalog.Languages()), "pairs", len(d.catalog.Pairs()))
+	}
+	if err != nil {
+		slog.Warn("Language discovery failed for some pipelines", "error", err)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package services
+
+import "testing"
+
+func TestPairLanguage(t *testing.T) {
+	tests := []struct {
+		code, script string
+		wantTag      string
+		wantScript   string
+	}{
+		{"hi", "", "hi", "Deva"},
+		{"hi", "Deva", "hi", "Deva"},
+		{"kok", "", "gom", "Deva"},
+		{"mni", "", "mni", "Mtei"},
+		{"mni", "Mtei", "mni", "Mtei"},
+		{"mni", "Beng", "mni-Beng", "Beng"},
+		{"ks", "Deva", "ks-Deva", "Deva"},
+		{"hi", "Latn", "hi-Latn", "Latn"}, // script the registry does not list
+		{"xx", "", "xx", ""},              // language the registry does not list
+		{"xx", "Latn", "xx-Latn", "Latn"},
+	}
+	for _, tt := range tests {
+		tag, script := pairLanguage(tt.code, tt.script)
+		if tag != tt.wantTag || script != tt.wantScript {
+			t.Errorf("pairLanguage(%q, %q) = %q, %q, want %q, %q", tt.code, tt.script, tag, script, tt.wantTag, tt.wantScript)
+		}
+	}
+}
+
+func TestLanguageCatalogResolvePair(t *testing.T) {
+	catalog := &LanguageCatalog{}
+	catalog.supported = map[[2]string]bool{{"en", "hi"}: true, {"en", "mni-Beng"}: true}
+	catalog.languages = map[string]bool{"en": true, "hi": true, "mni-Beng": true}
+
+	tests := []struct {
+		source, target         string
+		wantSource, wantTarget string
+		wantErr                string
+	}{
+		{"en", "hi", "en", "hi", ""},
+		{"en-IN", "hin", "en", "hi", ""},
+		{"eng", "mni_Beng", "en", "mni-Beng", ""},
+		{"hi", "en", "hi", "en", "translation from 'hi' to 'en' is not supported"},
+		{"ta", "hi", "ta", "hi", "source_lang 'ta' is not supported"},
+		{"en", "mni", "en", "mni", "target_lang 'mni' is not supported"},
+	}
+	for _, tt := range tests {
+		source, target := tt.source, tt.target
+		errMsg := catalog.ResolvePair(&source, &target)
+		if errMsg != tt.wantErr || source != tt.wantSource || target != tt.wantTarget {
+			t.Errorf("ResolvePair(%q, %q) = %q, %q, %q, want %q, %q, %q",
+				tt.source, tt.target, source, target, errMsg, tt.wantSource, tt.wantTarget, tt.wantErr)
+		}
+	}
+}
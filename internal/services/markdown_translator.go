@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// DefaultFrontMatterKeys are the front-matter fields whose values are
+// translated when the caller does not choose any
+var DefaultFrontMatterKeys = []string{"title", "description"}
+
+// MarkdownTranslator translates CommonMark documents block by block. Only the
+// prose inside paragraphs, headings, list items and table cells is sent
+// upstream; code, URLs, link targets, HTML and markup are copied from the
+// source untouched.
+type MarkdownTranslator struct {
+	translationService *TranslationService
+	parser             goldmark.Markdown
+}
+
+// NewMarkdownTranslator creates a new Markdown translator
+func NewMarkdownTranslator(translationService *TranslationService) *MarkdownTranslator {
+	return &MarkdownTranslator{
+		translationService: translationService,
+		// GFM adds tables and turns bare URLs into autolinks so they are protected
+		parser: goldmark.New(goldmark.WithExtensions(extension.GFM)),
+	}
+}
+
+// markdownEdit replaces source[start:stop] with a translated block
+type markdownEdit struct {
+	start, stop int
+	marked      *markedText
+	runs        []text.Segment
+}
+
+// Translate translates a Markdown document. frontMatterKeys lists the YAML
+// front-matter fields whose values should be translated; keys themselves are
+// never changed.
+func (t *MarkdownTranslator) Translate(ctx context.Context, document, sourceLang, targetLang string, frontMatterKeys []string) (string, error) {
+	frontMatter, body := splitFrontMatter(document)
+
+	translatedFrontMatter, err := t.translateFrontMatter(ctx, frontMatter, sourceLang, targetLang, frontMatterKeys)
+	if err != nil {
+		return "", err
+	}
+
+	source := []byte(body)
+	root := t.parser.Parser().Parse(text.NewReader(source))
+
+	// Every block that holds inline content becomes one translation unit
+	var edits []*markdownEdit
+	err = ast.Walk(root, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node.(type) {
+		case *ast.Paragraph, *ast.TextBlock, *ast.Heading, *east.TableCell:
+			if edit := buildMarkdownEdit(node, source); edit != nil {
+				edits = append(edits, edit)
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	replacements, err := t.translateEdits(ctx, edits, source, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+
+	// Apply the edits back to front so earlier offsets stay valid
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	output := source
+	for _, edit := range edits {
+		var buf bytes.Buffer
+		buf.Write(output[:edit.start])
+		buf.WriteString(replacements[edit])
+		buf.Write(output[edit.stop:])
+		output = buf.Bytes()
+	}
+
+	return translatedFrontMatter + string(output), nil
+}
+
+// translateEdits translates all blocks in one batch. Blocks whose placeholders
+// do not survive translation are retried with each text run translated on its
+// own and spliced between the original markup.
+func (t *MarkdownTranslator) translateEdits(ctx context.Context, edits []*markdownEdit, source []byte, sourceLang, targetLang string) (map[*markdownEdit]string, error) {
+	replacements := make(map[*markdownEdit]string, len(edits))
+
+	var blocks []string
+	var blockEdits, unmarked, unrestored []*markdownEdit
+	for _, edit := range edits {
+		if edit.marked == nil {
+			unmarked = append(unmarked, edit)
+			continue
+		}
+		blocks = append(blocks, edit.marked.String())
+		blockEdits = append(blockEdits, edit)
+	}
+
+	translated, err := t.translationService.TranslateTexts(ctx, blocks, sourceLang, targetLang)
+	if err != nil {
+		return nil, err
+	}
+	for i, edit := range blockEdits {
+		restored, ok := edit.marked.Restore(translated[i])
+		if !ok {
+			unrestored = append(unrestored, edit)
+			continue
+		}
+		replacements[edit] = restored
+	}
+
+	if err := t.translateRuns(ctx, unmarked, source, sourceLang, targetLang, replacements); err != nil {
+		return nil, err
+	}
+	// The unrestored blocks were charged to the quota as a whole already
+	if err := t.translateRuns(withPrepaidQuota(ctx), unrestored, source, sourceLang, targetLang, replacements); err != nil {
+		return nil, err
+	}
+	return replacements, nil
+}
+
+// translateRuns translates the text runs of blocks one by one and splices
+// them between the original markup
+func (t *MarkdownTranslator) translateRuns(ctx context.Context, edits []*markdownEdit, source []byte, sourceLang, targetLang string, replacements map[*markdownEdit]string) error {
+	if len(edits) == 0 {
+		return nil
+	}
+
+	var runs []string
+	for _, edit := range edits {
+		for _, run := range edit.runs {
+			runs = append(runs, string(run.Value(source)))
+		}
+	}
+	translatedRuns, err := t.translationService.TranslateTexts(ctx, runs, sourceLang, targetLang)
+	if err != nil {
+		return err
+	}
+
+	next := 0
+	for _, edit := range edits {
+		var buf strings.Builder
+		position := edit.start
+		for _, run := range edit.runs {
+			buf.Write(source[position:run.Start])
+			buf.WriteString(translatedRuns[next])
+			position = run.Stop
+			next++
+		}
+		buf.Write(source[position:edit.stop])
+		replacements[edit] = buf.String()
+	}
+	return nil
+}
+
+// buildMarkdownEdit collects the translatable text runs of a block and marks
+// everything between them as protected. It returns nil when the block has no
+// prose at all.
+func buildMarkdownEdit(block ast.Node, source []byte) *markdownEdit {
+	var runs []text.Segment
+	var hardBreaks []bool
+	_ = ast.Walk(block, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.CodeSpan, *ast.AutoLink, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if len(bytes.TrimSpace(n.Segment.Value(source))) > 0 {
+				runs = append(runs, n.Segment)
+				hardBreaks = append(hardBreaks, n.HardLineBreak())
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(runs) == 0 {
+		return nil
+	}
+
+	edit := &markdownEdit{
+		start: runs[0].Start,
+		stop:  runs[len(runs)-1].Stop,
+		runs:  runs,
+	}
+
+	marked := &markedText{}
+	for i, run := range runs {
+		if i > 0 {
+			gap := string(source[runs[i-1].Stop:run.Start])
+			// Soft line breaks and plain spacing can move freely with the words
+			if strings.TrimSpace(gap) == "" && !hardBreaks[i-1] {
+				if gap != "" {
+					marked.AddText(" ")
+				}
+			} else {
+				marked.AddProtected(gap)
+			}
+		}
+		marked.AddText(string(run.Value(source)))
+	}
+
+	if !hasPlaceholderSyntax(string(source[edit.start:edit.stop])) {
+		edit.marked = marked
+	}
+	return edit
+}
+
+// splitFrontMatter separates a leading YAML front-matter block, including its
+// closing fence, from the Markdown body
+func splitFrontMatter(document string) (string, string) {
+	if !strings.HasPrefix(document, "---\n") && !strings.HasPrefix(document, "---\r\n") {
+		return "", document
+	}
+
+	offset := strings.Index(document, "\n") + 1
+	for offset < len(document) {
+		end := strings.Index(document[offset:], "\n")
+		line := document[offset:]
+		if end >= 0 {
+			line = document[offset : offset+end+1]
+		}
+		if strings.TrimRight(line, "\r\n") == "---" {
+			offset += len(line)
+			return document[:offset], document[offset:]
+		}
+		offset += len(line)
+	}
+
+	// No closing fence, so this is a thematic break rather than front matter
+	return "", document
+}
+
+// translateFrontMatter translates the values of the selected top-level keys.
+// Only plain single-line scalars are touched; nested, multi-line and
+// non-selected values are left as they are.
+func (t *MarkdownTranslator) translateFrontMatter(ctx context.Context, frontMatter, sourceLang, targetLang string, keys []string) (string, error) {
+	if frontMatter == "" || len(keys) == 0 {
+		return frontMatter, nil
+	}
+
+	selected := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		selected[key] = true
+	}
+
+	lines := strings.SplitAfter(frontMatter, "\n")
+	var values []string
+	var valueLines []int
+	var quotes []string
+	for i, line := range lines {
+		key, value, found := strings.Cut(strings.TrimRight(line, "\r\n"), ":")
+		if !found || key != strings.TrimSpace(key) || !selected[key] {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		quote := ""
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			quote = value[:1]
+			value, found = unquoteYAML(value)
+			if !found {
+				continue
+			}
+		}
+		if value == "" || (quote == "" && strings.ContainsAny(value[:1], "|>[{&*!#")) {
+			continue
+		}
+
+		values = append(values, value)
+		valueLines = append(valueLines, i)
+		quotes = append(quotes, quote)
+	}
+
+	if len(values) == 0 {
+		return frontMatter, nil
+	}
+
+	translated, err := t.translationService.TranslateTexts(ctx, values, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+
+	for i, lineIndex := range valueLines {
+		line := lines[lineIndex]
+		key, _, _ := strings.Cut(line, ":")
+		ending := line[len(strings.TrimRight(line, "\r\n")):]
+
+		value := translated[i]
+		quote := quotes[i]
+		if quote == "" {
+			// Translations may contain characters that YAML would misread
+			quote = `"`
+		}
+		if quote == `"` {
+			value = strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `"`, `\"`)
+		} else {
+			value = strings.ReplaceAll(value, `'`, `''`)
+		}
+		lines[lineIndex] = key + ": " + quote + value + quote + ending
+	}
+
+	return strings.Join(lines, ""), nil
+}
+
+// unquoteYAML returns the value of a single- or double-quoted YAML scalar,
+// reporting false for escapes it cannot decode
+func unquoteYAML(value string) (string, bool) {
+	if value[0] == '\'' {
+		inner := value[1 : len(value)-1]
+		if strings.Contains(strings.ReplaceAll(inner, "''", ""), "'") {
+			return "", false
+		}
+		return strings.ReplaceAll(inner, "''", "'"), true
+	}
+	unquoted, err := strconv.Unquote(value)
+	return unquoted, err == nil
+}
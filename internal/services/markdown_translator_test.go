@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownTranslatorFrontMatter(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	translator := NewMarkdownTranslator(fake.service())
+
+	document := "---\n" +
+		"title: \"Say \\\"hi\\\" to C:\\\\\"\n" +
+		"summary: 'It''s here'\n" +
+		"tags: [one, two]\n" +
+		"author: plain\n" +
+		"---\n"
+	got, err := translator.Translate(context.Background(), document, "en", "hi", []string{"title", "summary", "tags"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "---\n" +
+		"title: \"SAY \\\"HI\\\" TO C:\\\\\"\n" +
+		"summary: 'IT''S HERE'\n" +
+		"tags: [one, two]\n" +
+		"author: plain\n" +
+		"---\n"
+	if got != want {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+	if inputs := fake.computeInputs(); len(inputs) != 1 || strings.Join(inputs[0], "|") != `Say "hi" to C:\|It's here` {
+		t.Errorf("compute inputs = %q, want the unescaped values", inputs)
+	}
+}
+
+func TestMarkdownTranslatorFallback(t *testing.T) {
+	// Dropping the placeholders forces every block back to its text runs
+	fake := newFakeBhashini(t, func(text string) string {
+		return strings.ToUpper(placeholderPattern.ReplaceAllString(text, ""))
+	})
+	translator := NewMarkdownTranslator(fake.service())
+
+	got, err := translator.Translate(context.Background(), "Some *bold* text and `code`.\n", "en", "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SOME *BOLD* TEXT AND `code`.\n"; got != want {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+	if inputs := fake.computeInputs(); len(inputs) != 2 {
+		t.Errorf("compute called %d times, want once for the block and once for its runs", len(inputs))
+	}
+}
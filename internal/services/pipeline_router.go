@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/constants"
+	"user-service/internal/metrics"
+)
+
+// Known Bhashini translation pipelines
+const (
+	PipelineInitial       = "64392f96daac500b55c543cd" // Initial Pipeline: translation, ASR, transliteration and TTS
+	PipelineIITBombay     = "660f813c0413087224435d2c" // IIT Bombay: translation
+	PipelineIIITHyderabad = "660f866443e53d4133f65317" // IIIT Hyderabad: translation
+)
+
+// pipelineAliases are the names routes may use instead of pipeline IDs
+var pipelineAliases = map[string]string{
+	"initial": PipelineInitial,
+	"iitb":    PipelineIITBombay,
+	"iiith":   PipelineIIITHyderabad,
+}
+
+// AnyLanguage matches every language in a route's language pair
+const AnyLanguage = "*"
+
+// Router defaults, overridden by the BHASHINI_PIPELINE_* variables
+const (
+	defaultPipelineDemoteAfter  = 3
+	defaultPipelineReprobeAfter = time.Minute
+)
+
+// Health score tuning
+const (
+	pipelineHealthWeight     = 0.2             // weight of the latest call in the moving averages
+	pipelineLatencyReference = 2 * time.Second // latency that halves the score
+	pipelineDemoteScore      = 0.3             // score below which a failing pipeline is demoted
+	pipelineMinCalls         = 10              // calls before the score can demote a pipeline
+	pipelineMaxBackoff       = 4               // doublings of the re-probe delay for pipelines that keep failing
+)
+
+// PipelineRoute maps a language pair to the pipelines that translate it, in
+// order of preference. Either language may be AnyLanguage.
+type PipelineRoute struct {
+	SourceLang string   `json:"source_lang"`
+	TargetLang string   `json:"target_lang"`
+	Pipelines  []string `json:"pipelines"`
+}
+
+// PipelineHealth is the health of one pipeline for one language pair
+type PipelineHealth struct {
+	PipelineID          string     `json:"pipeline_id"`
+	SourceLang          string     `json:"source_lang"`
+	TargetLang          string     `json:"target_lang"`
+	Score               float64    `json:"score"`
+	SuccessRate         float64    `json:"success_rate"`
+	LatencyMS           float64    `json:"latency_ms"`
+	Calls               int64      `json:"calls"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Demoted             bool       `json:"demoted"`
+	ReprobeAt           *time.Time `json:"reprobe_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// pipelineKey identifies a pipeline serving one language pair. Health is
+// tracked per pair, since a pipeline may serve some pairs well and others not
+// at all.
+type pipelineKey struct {
+	pipelineID string
+	sourceLang string
+	targetLang string
+}
+
+// pipelineHealth tracks the recent calls of one pipeline for one pair
+type pipelineHealth struct {
+	successRate  float64 // moving average, 1 for success and 0 for failure
+	latency      float64 // moving average of successful calls, in seconds
+	calls        int64
+	failures     int // consecutive failures
+	demotions    int // consecutive demotions, reset by a success
+	demotedUntil time.Time
+	lastError    string
+}
+
+// score combines the success rate and latency into a value between 0 and 1
+func (h *pipelineHealth) score() float64 {
+	reference := pipelineLatencyReference.Seconds()
+	return h.successRate * reference / (reference + h.latency)
+}
+
+// PipelineRouter chooses the pipelines a language pair is translated with.
+// Each pair has a chain of pipelines tried in turn; pipelines that keep
+// failing for a pair are demoted to the end of its chain and re-probed after
+// a delay. The router is shared by every service in the process and is safe
+// for concurrent use.
+type PipelineRouter struct {
+	routes       map[[2]string][]string
+	demoteAfter  int
+	reprobeAfter time.Duration
+
+	mu     sync.Mutex
+	health map[pipelineKey]*pipelineHealth
+}
+
+// NewPipelineRouter creates a router. Routes for AnyLanguage apply to every
+// pair without a more specific route; the route for AnyLanguage to
+// AnyLanguage is the default chain.
+func NewPipelineRouter(routes []PipelineRoute, demoteAfter int, reprobeAfter time.Duration) *PipelineRouter {
+	r := &PipelineRouter{
+		routes:       make(map[[2]string][]string),
+		demoteAfter:  demoteAfter,
+		reprobeAfter: reprobeAfter,
+		health:       make(map[pipelineKey]*pipelineHealth),
+	}
+	for _, route := range routes {
+		r.routes[[2]string{route.SourceLang, route.TargetLang}] = route.Pipelines
+	}
+	return r
+}
+
+// upstreamPipelineRouter returns the process's router, configured from the
+// environment on first use
+var upstreamPipelineRouter = sync.OnceValue(func() *PipelineRouter {
+	// The default chain starts with BHASHINI_PIPELINE_ID when it is set
+	defaultChain := []string{PipelineInitial, PipelineIITBombay, PipelineIIITHyderabad}
+	if pipelineID := os.Getenv("BHASHINI_PIPELINE_ID"); pipelineID != "" {
+		defaultChain = append([]string{pipelineID}, removePipeline(defaultChain, pipelineID)...)
+	}
+	routes := append([]PipelineRoute{{SourceLang: AnyLanguage, TargetLang: AnyLanguage, Pipelines: defaultChain}},
+		parsePipelineRoutes(os.Getenv("BHASHINI_PIPELINE_ROUTES"))...)
+
+	demoteAfter := defaultPipelineDemoteAfter
+	if value := os.Getenv("BHASHINI_PIPELINE_DEMOTE_AFTER"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			demoteAfter = parsed
+		}
+	}
+	reprobeAfter := defaultPipelineReprobeAfter
+	if value := os.Getenv("BHASHINI_PIPELINE_REPROBE_AFTER"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			reprobeAfter = parsed
+		}
+	}
+	return NewPipelineRouter(routes, demoteAfter, reprobeAfter)
+})
+
+// UpstreamPipelineRouter returns the router every translation service uses
+func UpstreamPipelineRouter() *PipelineRouter {
+	return upstreamPipelineRouter()
+}
+
+// parsePipelineRoutes parses routes written as
+//
+//	en:hi=iitb,initial;*:ta=iiith,initial;*:*=initial,iitb
+//
+// Languages are normalised to canonical tags, so "hi-IN" and "kok" route the
+// same pairs as "hi" and "gom". Pipelines are given by ID or by the aliases
+// initial, iitb and iiith. Malformed routes and routes for unknown languages
+// are logged and skipped.
+func parsePipelineRoutes(value string) []PipelineRoute {
+	var routes []PipelineRoute
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pair, list, ok := strings.Cut(entry, "=")
+		sourceLang, targetLang, pairOK := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || !pairOK || sourceLang == "" || targetLang == "" {
+			slog.Warn("Ignoring malformed pipeline route, expected source:target=pipeline,...", "route", entry)
+			continue
+		}
+		sourceLang, sourceOK := routeLanguage(sourceLang)
+		targetLang, targetOK := routeLanguage(targetLang)
+		if !sourceOK || !targetOK {
+			slog.Warn("Ignoring pipeline route for an unknown language", "route", entry)
+			continue
+		}
+
+		var pipelines []string
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if id, ok := pipelineAliases[strings.ToLower(name)]; ok {
+				name = id
+			}
+			if name != "" {
+				pipelines = append(pipelines, name)
+			}
+		}
+		if len(pipelines) == 0 {
+			slog.Warn("Ignoring pipeline route without pipelines", "route", entry)
+			continue
+		}
+		routes = append(routes, PipelineRoute{SourceLang: sourceLang, TargetLang: targetLang, Pipelines: pipelines})
+	}
+	return routes
+}
+
+// routeLanguage returns the canonical tag of a language in a route, keeping
+// AnyLanguage as it is
+func routeLanguage(code string) (string, bool) {
+	code = strings.TrimSpace(code)
+	if code == AnyLanguage {
+		return code, true
+	}
+	return constants.NormalizeLanguage(code)
+}
+
+// removePipeline returns a chain without a pipeline
+func removePipeline(chain []string, pipelineID string) []string {
+	var kept []string
+	for _, id := range chain {
+		if id != pipelineID {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// chain returns the configured chain of a pair: its own route, else the route
+// for its source language, else for its target language, else the default
+func (r *PipelineRouter) chain(sourceLang, targetLang string) []string {
+	for _, pair := range [][2]string{
+		{sourceLang, targetLang},
+		{sourceLang, AnyLanguage},
+		{AnyLanguage, targetLang},
+		{AnyLanguage, AnyLanguage},
+	} {
+		if pipelines, ok := r.routes[pair]; ok {
+			return pipelines
+		}
+	}
+	return nil
+}
+
+// Route returns the pipelines to try for a language pair, in order: the
+// pair's chain with demoted pipelines moved to the end, soonest re-probed
+// first. Demoted pipelines stay in the chain as a last resort.
+func (r *PipelineRouter) Route(sourceLang, targetLang string) []string {
+	chain := r.chain(sourceLang, targetLang)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var available, demoted []string
+	for _, pipelineID := range chain {
+		if h := r.health[pipelineKey{pipelineID, sourceLang, targetLang}]; h != nil && now.Before(h.demotedUntil) {
+			demoted = append(demoted, pipelineID)
+		} else {
+			available = append(available, pipelineID)
+		}
+	}
+	sort.SliceStable(demoted, func(i, j int) bool {
+		return r.health[pipelineKey{demoted[i], sourceLang, targetLang}].demotedUntil.Before(
+			r.health[pipelineKey{demoted[j], sourceLang, targetLang}].demotedUntil)
+	})
+	return append(available, demoted...)
+}
+
+// Record updates a pipeline's health for a pair with the outcome of a call.
+// Calls the caller gave up on and calls refused by an open circuit say
+// nothing about the pipeline and are not recorded.
+func (r *PipelineRouter) Record(ctx context.Context, pipelineID, sourceLang, targetLang string, latency time.Duration, err error) {
+	var circuitErr *CircuitOpenError
+	if ctx.Err() != nil || errors.As(err, &circuitErr) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := pipelineKey{pipelineID, sourceLang, targetLang}
+	h := r.health[key]
+	if h == nil {
+		h = &pipelineHealth{successRate: 1}
+		r.health[key] = h
+	}
+
+	h.calls++
+	outcome := 0.0
+	if err == nil {
+		outcome = 1
+		if h.latency == 0 {
+			h.latency = latency.Seconds()
+		} else {
+			h.latency += pipelineHealthWeight * (latency.Seconds() - h.latency)
+		}
+	}
+	h.successRate += pipelineHealthWeight * (outcome - h.successRate)
+
+	if err == nil {
+		h.failures, h.demotions = 0, 0
+		h.demotedUntil = time.Time{}
+	} else {
+		h.failures++
+		h.lastError = err.Error()
+		// Demote after repeated failures, a poor score, or a failed re-probe
+		if h.failures >= r.demoteAfter || (h.calls >= pipelineMinCalls && h.score() < pipelineDemoteScore) || h.demotions > 0 {
+			h.demotedUntil = time.Now().Add(r.reprobeAfter << min(h.demotions, pipelineMaxBackoff))
+			h.demotions++
+			metrics.PipelineDemotions.WithLabelValues(pipelineID).Inc()
+			slog.Warn("Demoting Bhashini pipeline", "pipeline_id", pipelineID, "source_lang", sourceLang, "target_lang", targetLang,
+				"consecutive_failures", h.failures, "reprobe_at", h.demotedUntil, "error", err)
+		}
+	}
+	metrics.PipelineHealth.WithLabelValues(pipelineID, sourceLang, targetLang).Set(h.score())
+}
+
+// Routes returns the configured routes, the default chain first
+func (r *PipelineRouter) Routes() []PipelineRoute {
+	routes := make([]PipelineRoute, 0, len(r.routes))
+	for pair, pipelines := range r.routes {
+		routes = append(routes, PipelineRoute{SourceLang: pair[0], TargetLang: pair[1], Pipelines: pipelines})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].SourceLang != routes[j].SourceLang {
+			return routes[i].SourceLang < routes[j].SourceLang
+		}
+		return routes[i].TargetLang < routes[j].TargetLang
+	})
+	return routes
+}
+
+// Pipelines returns every pipeline some route uses, the default chain's first
+func (r *PipelineRouter) Pipelines() []string {
+	var pipelines []string
+	seen := make(map[string]bool)
+	for _, route := range r.Routes() {
+		for _, pipelineID := range route.Pipelines {
+			if !seen[pipelineID] {
+				seen[pipelineID] = true
+				pipelines = append(pipelines, pipelineID)
+			}
+		}
+	}
+	return pipelines
+}
+
+// Health returns the health of every pipeline and pair called so far
+func (r *PipelineRouter) Health() []PipelineHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	health := make([]PipelineHealth, 0, len(r.health))
+	for key, h := range r.health {
+		status := PipelineHealth{
+			PipelineID:          key.pipelineID,
+			SourceLang:          key.sourceLang,
+			TargetLang:          key.targetLang,
+			Score:               h.score(),
+			SuccessRate:         h.successRate,
+			LatencyMS:           h.latency * 1000,
+			Calls:               h.calls,
+			ConsecutiveFailures: h.failures,
+			LastError:           h.lastError,
+		}
+		if now.Before(h.demotedUntil) {
+			reprobeAt := h.demotedUntil
+			status.Demoted, status.ReprobeAt = true, &reprobeAt
+		}
+		health = append(health, status)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		a, b := health[i], health[j]
+		if a.SourceLang != b.SourceLang {
+			return a.SourceLang < b.SourceLang
+		}
+		if a.TargetLang != b.TargetLang {
+			return a.TargetLang < b.TargetLang
+		}
+		return a.PipelineID < b.PipelineID
+	})
+	return health
+}
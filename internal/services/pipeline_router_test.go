@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePipelineRoutes(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []PipelineRoute
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  nil,
+		},
+		{
+			name:  "aliases and IDs",
+			value: "en:hi=iitb,initial; *:ta = iiith, custom-id ;*:*=initial",
+			want: []PipelineRoute{
+				{SourceLang: "en", TargetLang: "hi", Pipelines: []string{PipelineIITBombay, PipelineInitial}},
+				{SourceLang: AnyLanguage, TargetLang: "ta", Pipelines: []string{PipelineIIITHyderabad, "custom-id"}},
+				{SourceLang: AnyLanguage, TargetLang: AnyLanguage, Pipelines: []string{PipelineInitial}},
+			},
+		},
+		{
+			name:  "languages normalised to canonical tags",
+			value: "hi-IN:kok=iitb;EN:mni_Beng=initial;eng:mni-Mtei=iiith",
+			want: []PipelineRoute{
+				{SourceLang: "hi", TargetLang: "gom", Pipelines: []string{PipelineIITBombay}},
+				{SourceLang: "en", TargetLang: "mni-Beng", Pipelines: []string{PipelineInitial}},
+				{SourceLang: "en", TargetLang: "mni", Pipelines: []string{PipelineIIITHyderabad}},
+			},
+		},
+		{
+			name:  "malformed and unknown routes skipped",
+			value: "en-hi=iitb;en:=iitb;en:hi;en:hi=,;xx:hi=iitb;en:hi-Latn=iitb;en:ta=iiith",
+			want: []PipelineRoute{
+				{SourceLang: "en", TargetLang: "ta", Pipelines: []string{PipelineIIITHyderabad}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePipelineRoutes(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePipelineRoutes(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineRouterChain(t *testing.T) {
+	router := NewPipelineRouter([]PipelineRoute{
+		{SourceLang: AnyLanguage, TargetLang: AnyLanguage, Pipelines: []string{"default"}},
+		{SourceLang: "en", TargetLang: AnyLanguage, Pipelines: []string{"from-en"}},
+		{SourceLang: AnyLanguage, TargetLang: "ta", Pipelines: []string{"to-ta"}},
+		{SourceLang: "en", TargetLang: "hi", Pipelines: []string{"en-hi"}},
+	}, 3, time.Minute)
+
+	tests := []struct {
+		sourceLang, targetLang string
+		want                   []string
+	}{
+		{"en", "hi", []string{"en-hi"}},
+		{"en", "ta", []string{"from-en"}},
+		{"hi", "ta", []string{"to-ta"}},
+		{"hi", "bn", []string{"default"}},
+	}
+	for _, tt := range tests {
+		if got := router.Route(tt.sourceLang, tt.targetLang); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Route(%q, %q) = %v, want %v", tt.sourceLang, tt.targetLang, got, tt.want)
+		}
+	}
+}
+
+func TestPipelineRouterDemotion(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("API returned status 502")
+	router := NewPipelineRouter([]PipelineRoute{
+		{SourceLang: AnyLanguage, TargetLang: AnyLanguage, Pipelines: []string{"a", "b", "c"}},
+	}, 2, 50*time.Millisecond)
+
+	// One failure is not enough to demote
+	router.Record(ctx, "a", "en", "hi", time.Second, failure)
+	if got, want := router.Route("en", "hi"), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Route() after one failure = %v, want %v", got, want)
+	}
+
+	// Demoted pipelines move to the end, soonest re-probed first
+	router.Record(ctx, "b", "en", "hi", time.Second, failure)
+	router.Record(ctx, "b", "en", "hi", time.Second, failure)
+	router.Record(ctx, "a", "en", "hi", time.Second, failure)
+	if got, want := router.Route("en", "hi"), []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Route() after demotions = %v, want %v", got, want)
+	}
+
+	// Demotion applies to the failing pair only
+	if got, want := router.Route("en", "ta"), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Route() for another pair = %v, want %v", got, want)
+	}
+
+	// Calls the caller gave up on and calls refused by an open circuit are
+	// not recorded
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	router.Record(cancelled, "c", "en", "hi", time.Second, failure)
+	router.Record(ctx, "c", "en", "hi", 0, &CircuitOpenError{Endpoint: "compute", PipelineID: "c"})
+	router.Record(ctx, "c", "en", "hi", 0, &CircuitOpenError{Endpoint: "compute", PipelineID: "c"})
+	if got, want := router.Route("en", "hi"), []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Route() after unrecorded failures = %v, want %v", got, want)
+	}
+
+	// After the re-probe delay the pipelines are tried in order again, and a
+	// success keeps them there
+	time.Sleep(60 * time.Millisecond)
+	if got, want := router.Route("en", "hi"), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Route() after the re-probe delay = %v, want %v", got, want)
+	}
+	router.Record(ctx, "a", "en", "hi", time.Second, nil)
+
+	// A failed re-probe demotes straight away
+	router.Record(ctx, "b", "en", "hi", time.Second, failure)
+	if got, want := router.Route("en", "hi"), []string{"a", "c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Route() after a failed re-probe = %v, want %v", got, want)
+	}
+}
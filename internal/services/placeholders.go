@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// segmentBoundary separates pieces of one translation unit that must be split
+// apart again afterwards, such as subtitle cues or formatted Word runs. It is
+// added as a protected span, so it comes back exactly where the model put it.
+const segmentBoundary = "\x1e"
+
+// placeholderPattern matches the tokens written by markedText. Translation models
+// sometimes add spaces inside the braces, so those are tolerated on the way back.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\d+)\s*\}\}`)
+
+// markedText is a translation unit whose protected spans (markup, code, URLs)
+// are swapped for numbered placeholders before it is sent upstream, so the
+// model sees a whole sentence instead of disjoint fragments.
+type markedText struct {
+	builder strings.Builder
+	spans   []string
+}
+
+// AddText appends translatable text
+func (m *markedText) AddText(text string) {
+	m.builder.WriteString(text)
+}
+
+// AddProtected appends a span that must come back byte-for-byte
+func (m *markedText) AddProtected(span string) {
+	fmt.Fprintf(&m.builder, "{{%d}}", len(m.spans))
+	m.spans = append(m.spans, span)
+}
+
+// String returns the text to translate
+func (m *markedText) String() string {
+	return m.builder.String()
+}
+
+// Restore puts the protected spans back into a translation. It reports false
+// when the model dropped, duplicated or invented a placeholder, in which case
+// the caller has to fall back to translating the pieces on their own.
+func (m *markedText) Restore(translated string) (string, bool) {
+	seen := make([]bool, len(m.spans))
+	ok := true
+
+	restored := placeholderPattern.ReplaceAllStringFunc(translated, func(token string) string {
+		index, err := strconv.Atoi(placeholderPattern.FindStringSubmatch(token)[1])
+		if err != nil || index >= len(m.spans) || seen[index] {
+			ok = false
+			return token
+		}
+		seen[index] = true
+		return m.spans[index]
+	})
+
+	for _, found := range seen {
+		if !found {
+			ok = false
+		}
+	}
+	return restored, ok
+}
+
+// hasPlaceholderSyntax reports whether text already contains something that
+// looks like a placeholder, which would make restoring ambiguous
+func hasPlaceholderSyntax(text string) bool {
+	return placeholderPattern.MatchString(text)
+}
@@ -0,0 +1,99 @@
+package services
+
+import "testing"
+
+func TestMarkedTextRoundTrip(t *testing.T) {
+	var m markedText
+	m.AddText("Read the ")
+	m.AddProtected("<a href=\"https://example.com\">")
+	m.AddText("guide")
+	m.AddProtected("</a>")
+	m.AddText(" and run ")
+	m.AddProtected("`go test`")
+
+	if got, want := m.String(), "Read the {{0}}guide{{1}} and run {{2}}"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		name       string
+		translated string
+		want       string
+		wantOK     bool
+	}{
+		{
+			name:       "unchanged",
+			translated: "Read the {{0}}guide{{1}} and run {{2}}",
+			want:       "Read the <a href=\"https://example.com\">guide</a> and run `go test`",
+			wantOK:     true,
+		},
+		{
+			name:       "reordered",
+			translated: "{{2}} चलाएँ और {{0}}गाइड{{1}} पढ़ें",
+			want:       "`go test` चलाएँ और <a href=\"https://example.com\">गाइड</a> पढ़ें",
+			wantOK:     true,
+		},
+		{
+			name:       "spaces inside braces",
+			translated: "{{ 0 }}guide{{1 }} {{ 2}}",
+			want:       "<a href=\"https://example.com\">guide</a> `go test`",
+			wantOK:     true,
+		},
+		{
+			name:       "dropped",
+			translated: "{{0}}guide{{1}}",
+			want:       "<a href=\"https://example.com\">guide</a>",
+			wantOK:     false,
+		},
+		{
+			name:       "duplicated",
+			translated: "{{0}}{{0}}guide{{1}} {{2}}",
+			want:       "<a href=\"https://example.com\">{{0}}guide</a> `go test`",
+			wantOK:     false,
+		},
+		{
+			name:       "invented",
+			translated: "{{0}}guide{{1}} {{2}} {{3}}",
+			want:       "<a href=\"https://example.com\">guide</a> `go test` {{3}}",
+			wantOK:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.Restore(tt.translated)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Restore(%q) = %q, %v, want %q, %v", tt.translated, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMarkedTextSegmentBoundary(t *testing.T) {
+	var m markedText
+	m.AddText("Welcome to the")
+	m.AddProtected(segmentBoundary)
+	m.AddText(" training session.")
+
+	got, ok := m.Restore("प्रशिक्षण{{0}} सत्र में आपका स्वागत है।")
+	if want := "प्रशिक्षण" + segmentBoundary + " सत्र में आपका स्वागत है।"; got != want || !ok {
+		t.Errorf("Restore() = %q, %v, want %q, true", got, ok, want)
+	}
+}
+
+func TestHasPlaceholderSyntax(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"plain text", false},
+		{"a {{0}} token", true},
+		{"a {{ 12 }} token", true},
+		{"a {{name}} template", false},
+		{"{single} braces {1}", false},
+	}
+	for _, tt := range tests {
+		if got := hasPlaceholderSyntax(tt.text); got != tt.want {
+			t.Errorf("hasPlaceholderSyntax(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
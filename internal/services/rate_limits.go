@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"user-service/internal/logging"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+// KeyLimits are the limits that apply to an API key once its own settings
+// and the RATE_LIMIT_RPS, CHAR_QUOTA and CHAR_QUOTA_PERIOD defaults are
+// combined. Zero means unlimited.
+type KeyLimits struct {
+	RateLimit       int
+	CharQuota       int64
+	CharQuotaPeriod string
+}
+
+// LimitsFor resolves the limits of an API key. The admin key, which has no
+// ID, is never limited.
+func LimitsFor(key *models.APIKey) KeyLimits {
+	if key == nil || key.ID == "" {
+		return KeyLimits{}
+	}
+
+	limits := KeyLimits{CharQuotaPeriod: models.QuotaPeriodMonth}
+	if rps, err := strconv.Atoi(os.Getenv("RATE_LIMIT_RPS")); err == nil && rps > 0 {
+		limits.RateLimit = rps
+	}
+	if quota, err := strconv.ParseInt(os.Getenv("CHAR_QUOTA"), 10, 64); err == nil && quota > 0 {
+		limits.CharQuota = quota
+	}
+	if period := os.Getenv("CHAR_QUOTA_PERIOD"); period == models.QuotaPeriodDay || period == models.QuotaPeriodMonth {
+		limits.CharQuotaPeriod = period
+	}
+
+	if key.RateLimit != nil {
+		limits.RateLimit = *key.RateLimit
+	}
+	if key.CharQuota != nil {
+		limits.CharQuota = *key.CharQuota
+	}
+	if key.CharQuotaPeriod != nil {
+		limits.CharQuotaPeriod = *key.CharQuotaPeriod
+	}
+	return limits
+}
+
+// RateLimitStatus describes a key's request rate after counting a request
+type RateLimitStatus struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// defaultRateLimitTimeout is how long a rate limit check may take before the
+// request is let through, overridden by RATE_LIMIT_TIMEOUT
+const defaultRateLimitTimeout = 200 * time.Millisecond
+
+// RateLimiter enforces per-key requests per second. Counters live in
+// Postgres, so the limit holds across replicas.
+type RateLimiter struct {
+	repo    *repository.RateLimitRepository
+	timeout time.Duration
+}
+
+// NewRateLimiter creates a new rate limiter
+func NewRateLimiter(db *sql.DB) *RateLimiter {
+	timeout := defaultRateLimitTimeout
+	if value := os.Getenv("RATE_LIMIT_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			timeout = parsed
+		}
+	}
+	return &RateLimiter{repo: repository.NewRateLimitRepository(db), timeout: timeout}
+}
+
+// Allow counts a request for a key. It returns nil when the key has no rate
+// limit. Requests are let through if the counter cannot be updated within
+// RATE_LIMIT_TIMEOUT, so a slow or unavailable database does not take the
+// API down.
+func (l *RateLimiter) Allow(ctx context.Context, key *models.APIKey) *RateLimitStatus {
+	limit := LimitsFor(key).RateLimit
+	if limit <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	status := &RateLimitStatus{Allowed: true, Limit: limit, Remaining: limit, Reset: now.Truncate(time.Second).Add(time.Second)}
+	checkCtx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	requests, err := l.repo.CountRequest(checkCtx, key.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Rate limit check failed", "key_prefix", key.Prefix, "error", err)
+		return status
+	}
+
+	status.Allowed = requests <= limit
+	status.Remaining = max(limit-requests, 0)
+	return status
+}
+
+// QuotaExceededError is returned when a translation would take a key over
+// its character quota
+type QuotaExceededError struct {
+	Limit      int64
+	Used       int64
+	Period     string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("character quota exceeded: %d of %d characters per %s used, resets in %s",
+		e.Used, e.Limit, e.Period, e.RetryAfter.Round(time.Second))
+}
+
+// QuotaTooSmallError is returned when a translation needs more characters
+// than the key's quota allows in a whole period. Unlike a QuotaExceededError,
+// waiting for the quota to reset does not help.
+type QuotaTooSmallError struct {
+	Limit      int64
+	Characters int64
+	Period     string
+}
+
+func (e *QuotaTooSmallError) Error() string {
+	return fmt.Sprintf("translation needs %d characters, more than the character quota of %d per %s",
+		e.Characters, e.Limit, e.Period)
+}
+
+// CharQuota charges the characters a key translates against its daily or
+// monthly quota, before anything is sent upstream
+type CharQuota struct {
+	repo            *repository.RateLimitRepository
+	keyID           string
+	keyPrefix       string
+	limit           int64
+	period          string
+	exemptCacheHits bool
+}
+
+// NewCharQuota returns the character quota of a key, or nil when it has none
+func NewCharQuota(db *sql.DB, key *models.APIKey) *CharQuota {
+	limits := LimitsFor(key)
+	if limits.CharQuota <= 0 {
+		return nil
+	}
+	return &CharQuota{
+		repo:            repository.NewRateLimitRepository(db),
+		keyID:           key.ID,
+		keyPrefix:       key.Prefix,
+		limit:           limits.CharQuota,
+		period:          limits.CharQuotaPeriod,
+		exemptCacheHits: key.QuotaExemptCacheHits,
+	}
+}
+
+// reserve charges characters to the quota, or returns a QuotaExceededError,
+// or a QuotaTooSmallError when they would not fit even in an unused period
+func (q *CharQuota) reserve(ctx context.Context, characters int64) error {
+	if characters == 0 {
+		return nil
+	}
+	if characters > q.limit {
+		return &QuotaTooSmallError{Limit: q.limit, Characters: characters, Period: q.period}
+	}
+
+	charged, used, resetIn, err := q.repo.ReserveCharacters(ctx, q.keyID, q.period, characters, q.limit)
+	if err != nil {
+		return fmt.Errorf("failed to check character quota: %w", err)
+	}
+	if !charged {
+		return &QuotaExceededError{Limit: q.limit, Used: used, Period: q.period, RetryAfter: resetIn}
+	}
+	return nil
+}
+
+// release gives back characters that were reserved but never sent upstream,
+// even when the request has been cancelled
+func (q *CharQuota) release(ctx context.Context, characters int64) {
+	if characters == 0 {
+		return
+	}
+	if err := q.repo.ReleaseCharacters(context.WithoutCancel(ctx), q.keyID, q.period, characters); err != nil {
+		logging.FromContext(ctx).Error("Quota release failed", "key_prefix", q.keyPrefix, "error", err)
+	}
+}
+
+// countCharacters counts the characters of texts as Unicode code points
+func countCharacters(texts []string) int64 {
+	var n int64
+	for _, text := range texts {
+		n += int64(utf8.RuneCountInString(text))
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+func TestLimitsFor(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "5")
+	t.Setenv("CHAR_QUOTA", "1000")
+	t.Setenv("CHAR_QUOTA_PERIOD", "day")
+
+	rateLimit, charQuota := 0, int64(0)
+	tests := []struct {
+		name string
+		key  *models.APIKey
+		want KeyLimits
+	}{
+		{"admin key", &models.APIKey{}, KeyLimits{}},
+		{"defaults", &models.APIKey{ID: "k"}, KeyLimits{RateLimit: 5, CharQuota: 1000, CharQuotaPeriod: models.QuotaPeriodDay}},
+		{"unlimited key", &models.APIKey{ID: "k", APIKeyLimits: models.APIKeyLimits{RateLimit: &rateLimit, CharQuota: &charQuota}}, KeyLimits{CharQuotaPeriod: models.QuotaPeriodDay}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LimitsFor(tt.key); got != tt.want {
+				t.Errorf("LimitsFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCharQuotaReserveTooSmall(t *testing.T) {
+	// Neither reservation reaches the database, which the quota has none of
+	quota := &CharQuota{keyID: "k", limit: 100, period: models.QuotaPeriodMonth}
+
+	if err := quota.reserve(context.Background(), 0); err != nil {
+		t.Errorf("reserve(0) = %v, want nil", err)
+	}
+
+	err := quota.reserve(context.Background(), 101)
+	var tooSmall *QuotaTooSmallError
+	if !errors.As(err, &tooSmall) {
+		t.Fatalf("reserve(101) = %v, want a QuotaTooSmallError", err)
+	}
+	if tooSmall.Characters != 101 || tooSmall.Limit != 100 || tooSmall.Period != models.QuotaPeriodMonth {
+		t.Errorf("reserve(101) = %+v", tooSmall)
+	}
+	var exceeded *QuotaExceededError
+	if errors.As(err, &exceeded) {
+		t.Error("reserve(101) returned a retryable QuotaExceededError")
+	}
+}
+
+func TestMarkdownFallbackIsNotChargedTwice(t *testing.T) {
+	var reserved, released []int64
+	db := openFakeDB(t, func(query string, args []driver.Value) fakeResult {
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO api_key_character_usage"):
+			reserved = append(reserved, args[2].(int64))
+			return fakeResult{columns: []string{"characters", "reset"}, rows: [][]driver.Value{{args[2], 3600.0}}}
+		case strings.HasPrefix(query, "UPDATE api_key_character_usage"):
+			released = append(released, args[2].(int64))
+		}
+		return fakeResult{}
+	})
+
+	// Dropping the placeholders forces the block back to its text runs
+	fake := newFakeBhashini(t, func(text string) string {
+		return strings.ToUpper(placeholderPattern.ReplaceAllString(text, ""))
+	})
+	service := fake.service()
+	service.SetCaller(Caller{APIKeyID: "k", Quota: &CharQuota{
+		repo: repository.NewRateLimitRepository(db), keyID: "k", limit: 1000, period: models.QuotaPeriodMonth,
+	}})
+
+	if _, err := NewMarkdownTranslator(service).Translate(context.Background(), "Some *bold* text.\n", "en", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.computeInputs()) != 2 {
+		t.Fatalf("compute called %d times, want the fallback to run", len(fake.computeInputs()))
+	}
+	if len(reserved) != 1 || len(released) != 0 {
+		t.Errorf("reserved %v and released %v, want one reservation for the block", reserved, released)
+	}
+}
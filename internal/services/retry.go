@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Retry defaults, overridden by the BHASHINI_RETRY_* variables
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 10 * time.Second
+	defaultRetryBudget    = 10
+)
+
+// RetryPolicy decides how failed Bhashini calls are retried
+type RetryPolicy struct {
+	MaxAttempts int           // attempts per call, the first included; 1 disables retries
+	BaseDelay   time.Duration // wait before the first retry, doubled for each later one
+	MaxDelay    time.Duration // longest wait between attempts; a longer Retry-After is not waited for
+	Budget      int           // retries a client may make over all its calls
+}
+
+// DefaultRetryPolicy returns the retry policy configured by the environment
+func DefaultRetryPolicy() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: defaultRetryAttempts,
+		BaseDelay:   defaultRetryBaseDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+		Budget:      defaultRetryBudget,
+	}
+	if value := os.Getenv("BHASHINI_RETRY_ATTEMPTS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			policy.MaxAttempts = parsed
+		}
+	}
+	if value := os.Getenv("BHASHINI_RETRY_BASE_DELAY"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			policy.BaseDelay = parsed
+		}
+	}
+	if value := os.Getenv("BHASHINI_RETRY_MAX_DELAY"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			policy.MaxDelay = parsed
+		}
+	}
+	if value := os.Getenv("BHASHINI_RETRY_BUDGET"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			policy.Budget = parsed
+		}
+	}
+	return policy
+}
+
+// retryable reports whether a failed attempt may succeed if repeated:
+// network errors, 408, 429 and 5xx other than 501. Other 4xx responses,
+// authentication failures included, would only fail again, and a cancelled
+// or expired request context is final.
+func retryable(statusCode int, err error) bool {
+	if err != nil {
+		var circuitErr *CircuitOpenError
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.As(err, &circuitErr)
+	}
+	switch {
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusTooManyRequests:
+		return true
+	case statusCode == http.StatusNotImplemented:
+		return false
+	default:
+		return statusCode >= http.StatusInternalServerError
+	}
+}
+
+// delay returns how long to wait before retrying after a failed attempt:
+// the server's Retry-After when it sent one, otherwise exponential backoff
+// with jitter. It returns false when Retry-After asks for a longer wait than
+// MaxDelay.
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+
+	backoff := p.MaxDelay
+	if shift := attempt - 1; shift < 30 && p.BaseDelay<<shift < p.MaxDelay {
+		backoff = p.BaseDelay << shift
+	}
+	// Equal jitter: half the backoff is fixed, half random, so clients that
+	// failed together do not retry together
+	half := backoff / 2
+	return half + rand.N(half+1), true
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning zero when there is none
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		want       bool
+	}{
+		{"network error", 0, errors.New("connection reset by peer"), true},
+		{"cancelled", 0, fmt.Errorf("compute: %w", context.Canceled), false},
+		{"deadline", 0, context.DeadlineExceeded, false},
+		{"circuit open", 0, &CircuitOpenError{Endpoint: "compute", RetryAfter: time.Second}, false},
+		{"408", http.StatusRequestTimeout, nil, true},
+		{"429", http.StatusTooManyRequests, nil, true},
+		{"500", http.StatusInternalServerError, nil, true},
+		{"501", http.StatusNotImplemented, nil, false},
+		{"503", http.StatusServiceUnavailable, nil, true},
+		{"400", http.StatusBadRequest, nil, false},
+		{"401", http.StatusUnauthorized, nil, false},
+		{"403", http.StatusForbidden, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.statusCode, tt.err); got != tt.want {
+				t.Errorf("retryable(%d, %v) = %v, want %v", tt.statusCode, tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+		ok         bool
+	}{
+		{1, 0, 500 * time.Millisecond, time.Second, true},
+		{2, 0, time.Second, 2 * time.Second, true},
+		{3, 0, 2 * time.Second, 4 * time.Second, true},
+		{10, 0, 5 * time.Second, 10 * time.Second, true},
+		{64, 0, 5 * time.Second, 10 * time.Second, true},
+		{1, 3 * time.Second, 3 * time.Second, 3 * time.Second, true},
+		{1, time.Minute, time.Minute, time.Minute, false},
+	}
+	for _, tt := range tests {
+		delay, ok := policy.delay(tt.attempt, tt.retryAfter)
+		if delay < tt.min || delay > tt.max || ok != tt.ok {
+			t.Errorf("delay(%d, %s) = %s, %v, want %s to %s, %v", tt.attempt, tt.retryAfter, delay, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 2 * time.Minute, 2 * time.Minute},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		if got := parseRetryAfter(header); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s to %s", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestBhashiniClientRetries(t *testing.T) {
+	tests := []struct {
+		status int
+		calls  int
+	}{
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			fake := newFakeBhashini(t, upperCase)
+			fake.failWith(tt.status)
+			client := fake.client()
+			client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Budget: 10}
+
+			if _, err := NewTranslationService(client, nil).Translate(context.Background(), "Hello", "en", "hi"); err == nil {
+				t.Fatal("Translate() succeeded against a failing upstream")
+			}
+			if calls := len(fake.computeInputs()); calls != tt.calls {
+				t.Errorf("compute called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxSegmentChars is the longest sentence sent upstream as one input
+// when TRANSLATION_MAX_SEGMENT_CHARS is not set
+const DefaultMaxSegmentChars = 500
+
+// sentenceTerminators end a sentence: Latin punctuation, the Devanagari danda
+// and double danda, the ellipsis, and the Urdu full stop and question mark
+const sentenceTerminators = ".?!।॥…۔؟"
+
+// sentenceClosers may follow a terminator and still belong to the sentence
+const sentenceClosers = `"'”’)]»`
+
+// abbreviations are words that end with a full stop without ending a sentence
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true,
+	"jr": true, "st": true, "shri": true, "smt": true, "km": true, "vs": true,
+	"etc": true, "e.g": true, "i.e": true, "no": true, "fig": true, "inc": true,
+	"ltd": true, "co": true, "govt": true, "dept": true, "approx": true, "rs": true,
+}
+
+// Sentence is one piece of a segmented text together with the whitespace that
+// followed it in the original, so the pieces can be joined back exactly
+type Sentence struct {
+	Text     string
+	Trailing string
+}
+
+// SplitSentences splits text into sentences at sentence-final punctuation
+// (including । and ॥) and line breaks, skipping common abbreviations and
+// initials. Sentences longer than maxChars are split further at clause
+// punctuation or spaces. Leading whitespace is not part of any sentence.
+func SplitSentences(text string, maxChars int) []Sentence {
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+
+	var sentences []Sentence
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		end := i + size
+
+		boundary := false
+		switch {
+		case r == '\n':
+			boundary = true
+			end = i
+		case strings.ContainsRune(sentenceTerminators, r):
+			// Swallow repeated terminators and closing quotes ("?!", ".”)
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if !strings.ContainsRune(sentenceTerminators+sentenceClosers, next) {
+					break
+				}
+				end += nextSize
+			}
+			boundary = isSentenceEnd(text, start, i, end)
+		}
+
+		if !boundary {
+			i += size
+			continue
+		}
+
+		// The whitespace after the boundary belongs to this sentence
+		spaceEnd := end
+		for spaceEnd < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[spaceEnd:])
+			if !unicode.IsSpace(next) {
+				break
+			}
+			spaceEnd += nextSize
+		}
+
+		sentences = appendSentence(sentences, text[start:end], text[end:spaceEnd], maxChars)
+		start = spaceEnd
+		i = max(spaceEnd, i+size)
+	}
+
+	if start < len(text) {
+		sentences = appendSentence(sentences, text[start:], "", maxChars)
+	}
+	return sentences
+}
+
+// isSentenceEnd decides whether the terminator at text[at] (with any closers
+// up to end) really ends the sentence that began at start
+func isSentenceEnd(text string, start, at, end int) bool {
+	// Indic and Urdu terminators always end a sentence, ? and ! do unless
+	// something other than whitespace follows, as in a URL query string
+	if text[at] != '.' {
+		return end == len(text) || startsWithSpace(text[end:]) || text[at] != '?' && text[at] != '!'
+	}
+
+	// A full stop needs whitespace (or the end) after it: 3.14, example.com
+	if end < len(text) && !startsWithSpace(text[end:]) {
+		return false
+	}
+
+	// Look at the word before the full stop
+	word := text[start:at]
+	if space := strings.LastIndexFunc(word, unicode.IsSpace); space >= 0 {
+		word = word[space+1:]
+	}
+	word = strings.TrimLeft(word, sentenceClosers+"(")
+	if abbreviations[strings.ToLower(word)] {
+		return false
+	}
+	// Single-letter initials such as "A. P. J. Abdul Kalam"
+	if utf8.RuneCountInString(word) == 1 {
+		if r, _ := utf8.DecodeRuneInString(word); unicode.IsUpper(r) {
+			return false
+		}
+	}
+
+	// A lowercase continuation is rarely a new sentence
+	rest := strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	if next, _ := utf8.DecodeRuneInString(rest); rest != "" && unicode.IsLower(next) {
+		return false
+	}
+	return true
+}
+
+// appendSentence adds a sentence, splitting it when it exceeds maxChars
+func appendSentence(sentences []Sentence, text, trailing string, maxChars int) []Sentence {
+	trimmed := strings.TrimRightFunc(text, unicode.IsSpace)
+	text, trailing = trimmed, text[len(trimmed):]+trailing
+
+	if text == "" {
+		// Blank lines only add whitespace to the previous sentence
+		if n := len(sentences); n > 0 {
+			sentences[n-1].Trailing += trailing
+		}
+		return sentences
+	}
+
+	for maxChars > 0 && utf8.RuneCountInString(text) > maxChars {
+		cut := longSentenceCut(text, maxChars)
+		piece := strings.TrimRightFunc(text[:cut], unicode.IsSpace)
+		rest := strings.TrimLeftFunc(text[cut:], unicode.IsSpace)
+		sentences = append(sentences, Sentence{Text: piece, Trailing: text[len(piece) : len(text)-len(rest)]})
+		text = rest
+	}
+	return append(sentences, Sentence{Text: text, Trailing: trailing})
+}
+
+// longSentenceCut finds where to cut an over-long sentence: after the last
+// clause punctuation within the limit, else at the last space, else hard at
+// the limit
+func longSentenceCut(text string, maxChars int) int {
+	limit := len(text)
+	count := 0
+	for i := range text {
+		if count == maxChars {
+			limit = i
+			break
+		}
+		count++
+	}
+
+	window := text[:limit]
+	if cut := strings.LastIndexAny(window, ",;:،"); cut > 0 {
+		_, size := utf8.DecodeRuneInString(window[cut:])
+		return cut + size
+	}
+	if cut := strings.LastIndexFunc(window, unicode.IsSpace); cut > 0 {
+		return cut
+	}
+	return limit
+}
+
+// startsWithSpace reports whether text begins with whitespace
+func startsWithSpace(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsSpace(r)
+}
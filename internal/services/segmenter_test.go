@@ -0,0 +1,97 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxChars int
+		want     []Sentence
+	}{
+		{
+			name: "empty",
+			text: "  \n ",
+			want: nil,
+		},
+		{
+			name: "latin punctuation",
+			text: "Hello there. How are you? Fine!",
+			want: []Sentence{{"Hello there.", " "}, {"How are you?", " "}, {"Fine!", ""}},
+		},
+		{
+			name: "danda and double danda",
+			text: "मैं घर जा रहा हूँ। तुम कहाँ हो॥ ठीक है",
+			want: []Sentence{{"मैं घर जा रहा हूँ।", " "}, {"तुम कहाँ हो॥", " "}, {"ठीक है", ""}},
+		},
+		{
+			name: "urdu full stop and question mark",
+			text: "یہ کتاب ہے۔ کیا حال ہے؟",
+			want: []Sentence{{"یہ کتاب ہے۔", " "}, {"کیا حال ہے؟", ""}},
+		},
+		{
+			name: "repeated terminators and closing quotes",
+			text: `He asked "really?!" Then he left.`,
+			want: []Sentence{{`He asked "really?!"`, " "}, {"Then he left.", ""}},
+		},
+		{
+			name: "abbreviations and initials",
+			text: "Dr. Rao met A. P. J. Abdul Kalam. They talked.",
+			want: []Sentence{{"Dr. Rao met A. P. J. Abdul Kalam.", " "}, {"They talked.", ""}},
+		},
+		{
+			name: "full stop without a following space",
+			text: "Pi is 3.14 and the site is example.com. Visit it.",
+			want: []Sentence{{"Pi is 3.14 and the site is example.com.", " "}, {"Visit it.", ""}},
+		},
+		{
+			name: "lowercase continuation",
+			text: "The price is approx. ten rupees. ok then.",
+			want: []Sentence{{"The price is approx. ten rupees. ok then.", ""}},
+		},
+		{
+			name: "question mark inside a URL",
+			text: "Open https://example.com/?q=1 now. Done",
+			want: []Sentence{{"Open https://example.com/?q=1 now.", " "}, {"Done", ""}},
+		},
+		{
+			name: "line breaks and blank lines",
+			text: "  First line\nSecond line\n\nThird line\n",
+			want: []Sentence{{"First line", "\n"}, {"Second line", "\n\n"}, {"Third line", "\n"}},
+		},
+		{
+			name:     "long sentence cut at clause punctuation",
+			text:     "one two, three four five six",
+			maxChars: 12,
+			want:     []Sentence{{"one two,", " "}, {"three four", " "}, {"five six", ""}},
+		},
+		{
+			name:     "long word cut at the limit",
+			text:     "abcdefghij",
+			maxChars: 4,
+			want:     []Sentence{{"abcd", ""}, {"efgh", ""}, {"ij", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitSentences(tt.text, tt.maxChars)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("SplitSentences(%q, %d) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+			}
+
+			// The pieces join back into the text, less its leading whitespace
+			var joined strings.Builder
+			for _, sentence := range got {
+				joined.WriteString(sentence.Text + sentence.Trailing)
+			}
+			if want := strings.TrimLeftFunc(tt.text, unicode.IsSpace); got != nil && joined.String() != want {
+				t.Errorf("joined sentences = %q, want %q", joined.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Subtitle formats accepted by SubtitleTranslator
+const (
+	SubtitleFormatSRT = "srt"
+	SubtitleFormatVTT = "vtt"
+)
+
+// maxCuesPerSentence caps how many cues are merged into one translation unit
+// when a sentence keeps running across cue boundaries
+const maxCuesPerSentence = 4
+
+// subtitleTagPattern matches the inline styling that must survive translation:
+// WebVTT/SRT tags such as <i> or <v Speaker>, ASS overrides like {\an8} and
+// HTML entities
+var subtitleTagPattern = regexp.MustCompile(`<[^>\n]*>|\{\\[^}\n]*\}|&[A-Za-z0-9#]+;`)
+
+// ErrNoSubtitleCues is returned when a document contains no timed cues
+var ErrNoSubtitleCues = errors.New("no subtitle cues found")
+
+// SubtitleTranslator translates SRT and WebVTT cue text. Cue IDs, timing
+// lines, positioning settings, headers, NOTE/STYLE blocks and styling tags
+// are copied from the source byte-for-byte.
+type SubtitleTranslator struct {
+	translationService *TranslationService
+}
+
+// NewSubtitleTranslator creates a new subtitle translator
+func NewSubtitleTranslator(translationService *TranslationService) *SubtitleTranslator {
+	return &SubtitleTranslator{translationService: translationService}
+}
+
+// subtitleCue is the text part of one cue
+type subtitleCue struct {
+	firstLine int // index of the first text line
+	lines     []string
+	endings   []string
+	marked    *markedText
+}
+
+// DetectSubtitleFormat guesses the format of a subtitle document from its header
+func DetectSubtitleFormat(document string) string {
+	if strings.HasPrefix(strings.TrimPrefix(document, "\ufeff"), "WEBVTT") {
+		return SubtitleFormatVTT
+	}
+	return SubtitleFormatSRT
+}
+
+// Translate translates the cue text of an SRT or WebVTT document. Cues that
+// continue one sentence are translated together and split back into the
+// original cues.
+func (t *SubtitleTranslator) Translate(ctx context.Context, document, sourceLang, targetLang string) (string, error) {
+	lines := strings.SplitAfter(document, "\n")
+	cues := parseSubtitleCues(lines)
+	if len(cues) == 0 {
+		return "", ErrNoSubtitleCues
+	}
+
+	translated := make([]string, len(cues))
+
+	// First pass: whole sentences, with cue boundaries protected
+	var units [][]int
+	var unitTexts []string
+	var unitMarks []*markedText
+	for _, unit := range groupCuesIntoSentences(cues) {
+		marked := &markedText{}
+		for i, cueIndex := range unit {
+			if i > 0 {
+				marked.AddProtected(segmentBoundary)
+			}
+			addSubtitleText(marked, cues[cueIndex].lines)
+		}
+		if hasPlaceholderSyntax(strings.Join(cueLinesOf(cues, unit), " ")) {
+			continue
+		}
+		units = append(units, unit)
+		unitTexts = append(unitTexts, marked.String())
+		unitMarks = append(unitMarks, marked)
+	}
+
+	results, err := t.translationService.TranslateTexts(ctx, unitTexts, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+	for i, unit := range units {
+		restored, ok := unitMarks[i].Restore(results[i])
+		if !ok {
+			continue
+		}
+		parts := strings.Split(restored, segmentBoundary)
+		if len(parts) != len(unit) || hasEmptyPart(parts) {
+			continue
+		}
+		for j, cueIndex := range unit {
+			translated[cueIndex] = parts[j]
+		}
+	}
+
+	// Second pass: cues whose sentence could not be split back go one by one
+	var pending []int
+	var pendingTexts []string
+	for i := range cues {
+		if translated[i] != "" {
+			continue
+		}
+		cues[i].marked = &markedText{}
+		addSubtitleText(cues[i].marked, cues[i].lines)
+		pending = append(pending, i)
+		pendingTexts = append(pendingTexts, cues[i].marked.String())
+	}
+
+	if len(pending) > 0 {
+		results, err := t.translationService.TranslateTexts(ctx, pendingTexts, sourceLang, targetLang)
+		if err != nil {
+			return "", err
+		}
+		for j, i := range pending {
+			if restored, ok := cues[i].marked.Restore(results[j]); ok && !hasPlaceholderSyntax(strings.Join(cues[i].lines, " ")) {
+				translated[i] = restored
+				continue
+			}
+			// Last resort: keep the leading and trailing tags around plain text
+			plain := subtitleTagPattern.ReplaceAllString(results[j], "")
+			plain = placeholderPattern.ReplaceAllString(plain, "")
+			translated[i] = wrapWithEdgeTags(strings.Join(cues[i].lines, " "), strings.TrimSpace(plain))
+		}
+	}
+
+	// Write the translated text back over the original text lines
+	var output strings.Builder
+	next := 0
+	for i := 0; i < len(lines); {
+		if next < len(cues) && cues[next].firstLine == i {
+			cue := cues[next]
+			broken := breakSubtitleLines(strings.TrimSpace(translated[next]), len(cue.lines))
+			for k, line := range broken {
+				ending := cue.endings[0]
+				if k == len(broken)-1 {
+					ending = cue.endings[len(cue.endings)-1]
+				}
+				output.WriteString(line + ending)
+			}
+			i += len(cue.lines)
+			next++
+			continue
+		}
+		output.WriteString(lines[i])
+		i++
+	}
+
+	return output.String(), nil
+}
+
+// parseSubtitleCues finds the text lines of every cue. A cue is a block of
+// non-blank lines containing a timing line; the lines after the timing line
+// are its text. Blocks without a timing line are headers or notes.
+func parseSubtitleCues(lines []string) []subtitleCue {
+	var cues []subtitleCue
+	inBlock := false
+	seenTiming := false
+	for i, line := range lines {
+		content := strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(content) == "" {
+			inBlock = false
+			continue
+		}
+		if !inBlock {
+			inBlock = true
+			seenTiming = false
+		}
+
+		if !seenTiming {
+			if strings.Contains(content, "-->") {
+				seenTiming = true
+			}
+			continue
+		}
+
+		ending := line[len(content):]
+		if n := len(cues); n > 0 && cues[n-1].firstLine+len(cues[n-1].lines) == i {
+			cues[n-1].lines = append(cues[n-1].lines, content)
+			cues[n-1].endings = append(cues[n-1].endings, ending)
+			continue
+		}
+		cues = append(cues, subtitleCue{firstLine: i, lines: []string{content}, endings: []string{ending}})
+	}
+	return cues
+}
+
+// groupCuesIntoSentences groups consecutive cues until one ends a sentence
+func groupCuesIntoSentences(cues []subtitleCue) [][]int {
+	var units [][]int
+	var current []int
+	for i, cue := range cues {
+		current = append(current, i)
+		plain := subtitleTagPattern.ReplaceAllString(strings.Join(cue.lines, " "), "")
+		if endsSentence(plain) || len(current) == maxCuesPerSentence || i == len(cues)-1 {
+			units = append(units, current)
+			current = nil
+		}
+	}
+	return units
+}
+
+// endsSentence reports whether text ends with sentence-final punctuation,
+// ignoring closing quotes and brackets
+func endsSentence(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), `"'”’)]»`)
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return strings.ContainsRune(".?!।॥…", r)
+}
+
+// addSubtitleText appends cue lines to marked, protecting styling tags.
+// Line breaks inside a cue become plain spaces and are re-broken later.
+func addSubtitleText(marked *markedText, lines []string) {
+	for i, line := range lines {
+		if i > 0 {
+			marked.AddText(" ")
+		}
+		position := 0
+		for _, tag := range subtitleTagPattern.FindAllStringIndex(line, -1) {
+			marked.AddText(line[position:tag[0]])
+			marked.AddProtected(line[tag[0]:tag[1]])
+			position = tag[1]
+		}
+		marked.AddText(line[position:])
+	}
+}
+
+// wrapWithEdgeTags puts the tags that open and close the original cue text
+// around a plain translation
+func wrapWithEdgeTags(original, plain string) string {
+	var leading, trailing strings.Builder
+	rest := strings.TrimSpace(original)
+	for {
+		loc := subtitleTagPattern.FindStringIndex(rest)
+		if loc == nil || loc[0] != 0 {
+			break
+		}
+		leading.WriteString(rest[:loc[1]])
+		rest = strings.TrimLeft(rest[loc[1]:], " ")
+	}
+	for {
+		locs := subtitleTagPattern.FindAllStringIndex(rest, -1)
+		if len(locs) == 0 || locs[len(locs)-1][1] != len(rest) {
+			break
+		}
+		last := locs[len(locs)-1]
+		trailing.WriteString(rest[last[0]:])
+		rest = strings.TrimRight(rest[:last[0]], " ")
+	}
+
+	// Closing tags were collected from the end, so restore their order
+	closing := subtitleTagPattern.FindAllString(trailing.String(), -1)
+	for i, j := 0, len(closing)-1; i < j; i, j = i+1, j-1 {
+		closing[i], closing[j] = closing[j], closing[i]
+	}
+	return leading.String() + plain + strings.Join(closing, "")
+}
+
+// breakSubtitleLines splits text into up to n lines of similar length,
+// breaking only at spaces outside tags. Text that already contains line
+// breaks is returned as it is.
+func breakSubtitleLines(text string, n int) []string {
+	if n <= 1 || strings.Contains(text, "\n") {
+		return strings.Split(text, "\n")
+	}
+
+	var breaks []int
+	inTag := false
+	for i, r := range text {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case r == ' ' && !inTag:
+			breaks = append(breaks, i)
+		}
+	}
+
+	var lines []string
+	start := 0
+	for k := 1; k < n && len(breaks) > 0; k++ {
+		target := start + (len(text)-start)/(n-k+1)
+		best := -1
+		for _, b := range breaks {
+			if b <= start {
+				continue
+			}
+			if best == -1 || abs(b-target) < abs(best-target) {
+				best = b
+			}
+		}
+		if best == -1 {
+			break
+		}
+		lines = append(lines, text[start:best])
+		start = best + 1
+	}
+	return append(lines, text[start:])
+}
+
+// cueLinesOf returns the text lines of the given cues
+func cueLinesOf(cues []subtitleCue, indexes []int) []string {
+	var lines []string
+	for _, i := range indexes {
+		lines = append(lines, cues[i].lines...)
+	}
+	return lines
+}
+
+// hasEmptyPart reports whether any part is blank
+func hasEmptyPart(parts []string) bool {
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// abs returns the absolute value of an integer
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
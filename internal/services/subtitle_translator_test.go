@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSubtitleTranslatorSRT(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	translator := NewSubtitleTranslator(fake.service())
+
+	document := "1\r\n00:00:01,000 --> 00:00:02,000\r\nThis sentence runs\r\n\r\n" +
+		"2\r\n00:00:02,000 --> 00:00:03,000\r\n<i>across two cues.</i>\r\n\r\n" +
+		"3\r\n00:00:04,000 --> 00:00:05,000\r\nSecond line\r\nof one cue.\r\n"
+	got, err := translator.Translate(context.Background(), document, "en", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "1\r\n00:00:01,000 --> 00:00:02,000\r\nTHIS SENTENCE RUNS\r\n\r\n" +
+		"2\r\n00:00:02,000 --> 00:00:03,000\r\n<i>ACROSS TWO CUES.</i>\r\n\r\n" +
+		"3\r\n00:00:04,000 --> 00:00:05,000\r\nSECOND LINE\r\nOF ONE CUE.\r\n"
+	if got != want {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+	if inputs := fake.computeInputs(); len(inputs) != 1 {
+		t.Errorf("compute called %d times, want once", len(inputs))
+	}
+}
+
+func TestSubtitleTranslatorVTT(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	translator := NewSubtitleTranslator(fake.service())
+
+	document := "WEBVTT\n\nNOTE keep this\n\n" +
+		"intro\n00:01.000 --> 00:02.000 align:start\nHello there.\n"
+	if format := DetectSubtitleFormat(document); format != SubtitleFormatVTT {
+		t.Errorf("DetectSubtitleFormat() = %q, want vtt", format)
+	}
+	got, err := translator.Translate(context.Background(), document, "en", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\nNOTE keep this\n\n" +
+		"intro\n00:01.000 --> 00:02.000 align:start\nHELLO THERE.\n"
+	if got != want {
+		t.Errorf("Translate() = %q, want %q", got, want)
+	}
+}
+
+func TestSubtitleTranslatorNoCues(t *testing.T) {
+	translator := NewSubtitleTranslator(newFakeBhashini(t, upperCase).service())
+	if _, err := translator.Translate(context.Background(), "WEBVTT\n\nNOTE nothing\n", "en", "hi"); !errors.Is(err, ErrNoSubtitleCues) {
+		t.Errorf("Translate() = %v, want ErrNoSubtitleCues", err)
+	}
+}
@@ -1,34 +1,59 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"user-service/internal/logging"
+	"user-service/internal/metrics"
+	"user-service/internal/models"
 	"user-service/internal/repository"
+	"user-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Stale cache defaults, overridden by the TRANSLATION_CACHE_STALE_* variables
+const (
+	defaultStaleWhileRevalidate = time.Hour
+	defaultStaleIfError         = 7 * 24 * time.Hour
 )
 
 // TranslationService handles translation business logic with caching
 type TranslationService struct {
-	bhashiniClient *BhashiniClient
-	cacheRepo      *repository.TranslationRepository
-	defaultPipelineID string
-	cacheTTL       time.Duration
+	bhashiniClient  *BhashiniClient
+	cacheRepo       *repository.TranslationRepository
+	router          *PipelineRouter
+	cacheTTL        time.Duration
+	maxSegmentChars int
+	caller          Caller
+
+	// How long after expiry a cached translation is still served while it
+	// is refreshed, and how long it stands in when upstream fails
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	staleSentences       int
 }
 
-// NewTranslationService creates a new translation service
-func NewTranslationService(bhashiniClient *BhashiniClient, cacheRepo *repository.TranslationRepository) *TranslationService {
-	// Default pipeline ID for translation (can be overridden via env)
-	pipelineID := os.Getenv("BHASHINI_PIPELINE_ID")
-	if pipelineID == "" {
-		// Use a known valid pipeline ID for translation
-		// Valid IDs: 64392f96daac500b55c543cd (Initial), 660f813c0413087224435d2c (IIT Bombay), 660f866443e53d4133f65317 (IIIT Hyderabad)
-		pipelineID = "64392f96daac500b55c543cd" // Initial Pipeline - supports Translation
-	}
+// Caller identifies who translations are done for: the API key their usage
+// is metered against and, when the key has one, its character quota
+type Caller struct {
+	APIKeyID string
+	Quota    *CharQuota
+}
 
+// NewTranslationService creates a new translation service. cacheRepo may be
+// nil to translate without a cache, as the command-line client does.
+func NewTranslationService(bhashiniClient *BhashiniClient, cacheRepo *repository.TranslationRepository) *TranslationService {
 	// Parse cache TTL from env (default 24 hours)
 	cacheTTL := 24 * time.Hour
 	if ttlStr := os.Getenv("TRANSLATION_CACHE_TTL"); ttlStr != "" {
@@ -37,74 +62,442 @@ func NewTranslationService(bhashiniClient *BhashiniClient, cacheRepo *repository
 		}
 	}
 
+	// Parse the stale cache windows (default 1 hour and 7 days, 0 disables)
+	staleWhileRevalidate := defaultStaleWhileRevalidate
+	if value := os.Getenv("TRANSLATION_CACHE_STALE_WHILE_REVALIDATE"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed >= 0 {
+			staleWhileRevalidate = parsed
+		}
+	}
+	staleIfError := defaultStaleIfError
+	if value := os.Getenv("TRANSLATION_CACHE_STALE_IF_ERROR"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed >= 0 {
+			staleIfError = parsed
+		}
+	}
+
+	// Parse the longest sentence sent upstream as one input (default 500 chars)
+	maxSegmentChars := DefaultMaxSegmentChars
+	if maxStr := os.Getenv("TRANSLATION_MAX_SEGMENT_CHARS"); maxStr != "" {
+		if parsed, err := strconv.Atoi(maxStr); err == nil && parsed > 0 {
+			maxSegmentChars = parsed
+		}
+	}
+
 	return &TranslationService{
-		bhashiniClient:    bhashiniClient,
-		cacheRepo:        cacheRepo,
-		defaultPipelineID: pipelineID,
-		cacheTTL:         cacheTTL,
+		bhashiniClient:  bhashiniClient,
+		cacheRepo:       cacheRepo,
+		router:          upstreamPipelineRouter(),
+		cacheTTL:        cacheTTL,
+		maxSegmentChars: maxSegmentChars,
+
+		staleWhileRevalidate: staleWhileRevalidate,
+		staleIfError:         staleIfError,
 	}
 }
 
-// Translate translates text from source language to target language with caching
-func (s *TranslationService) Translate(sourceText, sourceLang, targetLang string) (string, error) {
+// StaleSentences returns how many sentences the service has translated from
+// expired cache entries, so callers can mark responses that include them
+func (s *TranslationService) StaleSentences() int {
+	return s.staleSentences
+}
+
+// SetCaller attributes the service's translations to a caller, charging them
+// to its character quota if it has one
+func (s *TranslationService) SetCaller(caller Caller) {
+	s.caller = caller
+}
+
+// prepaidQuotaKey marks a context whose translations were paid for already
+type prepaidQuotaKey struct{}
+
+// withPrepaidQuota returns a context whose translations are not charged to
+// the caller's quota, for text translated again in smaller pieces after the
+// whole of it was charged
+func withPrepaidQuota(ctx context.Context) context.Context {
+	return context.WithValue(ctx, prepaidQuotaKey{}, true)
+}
+
+// Translate translates text from source language to target language with caching.
+// Long texts are split into sentences, each translated and cached on its own.
+func (s *TranslationService) Translate(ctx context.Context, sourceText, sourceLang, targetLang string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "TranslationService.Translate", trace.WithAttributes(tracing.Pair(sourceLang, targetLang)...))
+	defer func() { tracing.End(span, err) }()
+
 	// Normalize input
 	sourceText = strings.TrimSpace(sourceText)
 	if sourceText == "" {
 		return "", fmt.Errorf("source text cannot be empty")
 	}
 
-	// Check cache first
-	if cached, found, err := s.cacheRepo.GetCachedTranslation(sourceText, sourceLang, targetLang); err == nil && found {
-		return cached, nil
-	} else if err != nil {
-		// Log error but continue with API call
-		fmt.Printf("Cache lookup error: %v\n", err)
+	translated, err := s.TranslateTexts(ctx, []string{sourceText}, sourceLang, targetLang)
+	if err != nil {
+		return "", err
+	}
+	return translated[0], nil
+}
+
+// maxInputsPerCompute caps how many sentences go upstream in one compute request
+const maxInputsPerCompute = 25
+
+// maxCharsPerCompute caps the total characters sent in one compute request, so
+// long documents become several short upstream calls instead of one slow one
+const maxCharsPerCompute = 5000
+
+// TranslateTexts translates several texts that share a language pair. Every
+// text is split into sentences, each sentence is looked up in the cache on its
+// own, and the misses are sent upstream together in as few compute requests as
+// possible. The original whitespace around and between sentences is kept, and
+// blank texts are returned unchanged.
+func (s *TranslationService) TranslateTexts(ctx context.Context, sourceTexts []string, sourceLang, targetLang string) (_ []string, err error) {
+	// Handlers pass canonical tags already; jobs queued before languages were
+	// normalised may hold other forms, such as "kok" for "gom"
+	sourceLang, targetLang = canonicalLanguage(sourceLang), canonicalLanguage(targetLang)
+	ctx, span := tracing.Start(ctx, "TranslationService.TranslateTexts", trace.WithAttributes(tracing.Pair(sourceLang, targetLang)...))
+	span.SetAttributes(tracing.AttrTexts.Int(len(sourceTexts)))
+	defer func() { tracing.End(span, err) }()
+
+	segmented := make([][]Sentence, len(sourceTexts))
+	var sentences []string
+	for i, text := range sourceTexts {
+		segmented[i] = SplitSentences(text, s.maxSegmentChars)
+		for _, sentence := range segmented[i] {
+			sentences = append(sentences, sentence.Text)
+		}
+	}
+
+	translated, err := s.translateSentences(ctx, sentences, sourceLang, targetLang)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, len(sourceTexts))
+	next := 0
+	for i, text := range sourceTexts {
+		if len(segmented[i]) == 0 {
+			results[i] = text
+			continue
+		}
+
+		var result strings.Builder
+		result.WriteString(text[:len(text)-len(strings.TrimLeftFunc(text, unicode.IsSpace))])
+		for _, sentence := range segmented[i] {
+			result.WriteString(strings.TrimSpace(translated[next]))
+			result.WriteString(sentence.Trailing)
+			next++
+		}
+		results[i] = result.String()
+	}
+
+	return results, nil
+}
+
+// sentenceUsage collects the usage of one translateSentences call
+type sentenceUsage struct {
+	models.UsageCounts
+	pipelineID string
+}
+
+// translateSentences translates trimmed, non-empty sentences, records the
+// call with the usage meter and annotates the current span with its cache
+// result and pipeline. Failed calls only count the upstream work done.
+func (s *TranslationService) translateSentences(ctx context.Context, sentences []string, sourceLang, targetLang string) ([]string, error) {
+	start := time.Now()
+	var usage sentenceUsage
+	results, err := s.translateThroughCache(ctx, sentences, sourceLang, targetLang, &usage)
+
+	cacheResult := tracing.CacheDisabled
+	if s.cacheRepo != nil {
+		switch usage.CacheHits {
+		case 0:
+			cacheResult = tracing.CacheMiss
+		case int64(len(sentences)):
+			cacheResult = tracing.CacheHit
+		default:
+			cacheResult = tracing.CachePartial
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		tracing.AttrSentences.Int(len(sentences)),
+		tracing.AttrCacheHits.Int64(usage.CacheHits),
+		tracing.AttrCacheResult.String(cacheResult),
+		tracing.AttrPipelineID.String(usage.pipelineID),
+	)
+
+	usage.Requests = 1
+	usage.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		usage.Errors = 1
+	} else {
+		usage.Sentences = int64(len(sentences))
+		usage.Characters = countCharacters(sentences)
+	}
+	recordUsage(s.caller.APIKeyID, sourceLang, targetLang, usage.pipelineID, usage.UsageCounts)
+
+	return results, err
+}
+
+// translateThroughCache translates sentences through the cache, sending each
+// distinct miss upstream once. Entries expired within the stale-while-
+// revalidate window are served and refreshed in the background; older ones
+// within the stale-if-error window stand in for their sentences when upstream
+// fails. With a quota, the characters are charged before anything goes
+// upstream and refunded for sentences that never made it.
+func (s *TranslationService) translateThroughCache(ctx context.Context, sentences []string, sourceLang, targetLang string, usage *sentenceUsage) ([]string, error) {
+	results := make([]string, len(sentences))
+
+	// Collect the distinct sentences that still need an upstream call
+	var pending, revalidate []string
+	pendingIndexes := make(map[string][]int)
+	fallbacks := make(map[string]string)
+	for i, sentence := range sentences {
+		if _, queued := pendingIndexes[sentence]; !queued {
+			if s.cacheRepo != nil {
+				cached, err := s.cacheRepo.GetCachedTranslation(ctx, sentence, sourceLang, targetLang, max(s.staleWhileRevalidate, s.staleIfError))
+				switch {
+				case err != nil:
+					// Log error but continue with API call
+					metrics.CacheErrors.WithLabelValues(metrics.CacheTierPostgres, "lookup").Inc()
+					logging.FromContext(ctx).Warn("Cache lookup failed", "error", err)
+				case cached == nil:
+					metrics.CacheMisses.WithLabelValues(metrics.CacheTierPostgres).Inc()
+				case !cached.Stale():
+					results[i] = cached.TranslatedText
+					usage.CacheHits++
+					metrics.CacheHits.WithLabelValues(metrics.CacheTierPostgres).Inc()
+					continue
+				case time.Since(cached.ExpiresAt) < s.staleWhileRevalidate:
+					results[i] = cached.TranslatedText
+					usage.CacheHits++
+					s.staleSentences++
+					revalidate = append(revalidate, sentence)
+					metrics.CacheHits.WithLabelValues(metrics.CacheTierPostgres).Inc()
+					metrics.CacheStale.WithLabelValues(metrics.CacheTierPostgres, metrics.StaleRevalidate).Inc()
+					continue
+				default:
+					// Too old to serve unless upstream fails
+					if time.Since(cached.ExpiresAt) < s.staleIfError {
+						fallbacks[sentence] = cached.TranslatedText
+					}
+					metrics.CacheMisses.WithLabelValues(metrics.CacheTierPostgres).Inc()
+				}
+			}
+			pending = append(pending, sentence)
+		}
+		pendingIndexes[sentence] = append(pendingIndexes[sentence], i)
+	}
+	if len(revalidate) > 0 {
+		s.revalidate(ctx, revalidate, sourceLang, targetLang)
+	}
+
+	// Charge the quota for the whole request, or only for the cache misses
+	quota := s.caller.Quota
+	if ctx.Value(prepaidQuotaKey{}) != nil {
+		quota = nil
+	}
+	var unsent int64
+	if quota != nil {
+		charged := countCharacters(pending)
+		if !quota.exemptCacheHits {
+			charged = countCharacters(sentences)
+		}
+		if err := quota.reserve(ctx, charged); err != nil {
+			return nil, err
+		}
+		unsent = countCharacters(pending)
+		defer func() {
+			quota.release(ctx, unsent)
+		}()
+	}
+
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	err := s.translateUpstream(ctx, pending, sourceLang, targetLang, usage, func(sourceText, translatedText string) {
+		for _, i := range pendingIndexes[sourceText] {
+			results[i] = translatedText
+		}
+		if quota != nil {
+			unsent -= countCharacters([]string{sourceText})
+		}
+	})
+	if err == nil {
+		return results, nil
+	}
+
+	// Serve stale translations for whatever upstream did not translate, but
+	// only when there is one for every such sentence
+	var missing []string
+	for _, sentence := range pending {
+		if results[pendingIndexes[sentence][0]] != "" {
+			continue
+		}
+		if _, ok := fallbacks[sentence]; !ok {
+			return nil, err
+		}
+		missing = append(missing, sentence)
+	}
+	for _, sentence := range missing {
+		for _, i := range pendingIndexes[sentence] {
+			results[i] = fallbacks[sentence]
+		}
+		usage.CacheHits++
+		s.staleSentences++
+		metrics.CacheStale.WithLabelValues(metrics.CacheTierPostgres, metrics.StaleError).Inc()
+	}
+	logging.FromContext(ctx).Warn("Serving stale cached translations after upstream failure", "sentences", len(missing), "error", err)
+	return results, nil
+}
+
+// translateUpstream sends sentences to Bhashini in chunks, caching each
+// translation and passing it to place as it arrives. The pipelines of the
+// pair's route are tried in turn: when one fails, the sentences it has not
+// translated go to the next. On failure, the sentences translated so far have
+// already been placed.
+func (s *TranslationService) translateUpstream(ctx context.Context, sentences []string, sourceLang, targetLang string, usage *sentenceUsage, place func(sourceText, translatedText string)) error {
+	var err error
+	done := 0
+	pipelines := s.router.Route(sourceLang, targetLang)
+	for i, pipelineID := range pipelines {
+		done, err = s.translateWithPipeline(ctx, pipelineID, sentences, done, sourceLang, targetLang, usage, place)
+		if err == nil || ctx.Err() != nil || i == len(pipelines)-1 {
+			break
+		}
+		metrics.PipelineFallbacks.WithLabelValues(pipelineID).Inc()
+		logging.FromContext(ctx).Warn("Falling back to the next Bhashini pipeline", "pipeline_id", pipelineID, "next_pipeline_id", pipelines[i+1], "error", err)
 	}
+	return err
+}
 
+// translateWithPipeline translates sentences from index start on with one
+// pipeline, recording each call with the router. It returns the index of the
+// first sentence left untranslated.
+func (s *TranslationService) translateWithPipeline(ctx context.Context, pipelineID string, sentences []string, start int, sourceLang, targetLang string, usage *sentenceUsage, place func(sourceText, translatedText string)) (int, error) {
 	// Get pipeline config
-	config, err := s.bhashiniClient.GetPipelineConfig(s.defaultPipelineID, sourceLang, targetLang)
+	configStart := time.Now()
+	config, err := s.bhashiniClient.GetPipelineConfig(ctx, pipelineID, sourceLang, targetLang)
 	if err != nil {
-		// If pipeline ID fails, try to find a valid one
-		if pipelineID, findErr := s.bhashiniClient.FindTranslationPipeline(); findErr == nil {
-			s.defaultPipelineID = pipelineID
-			config, err = s.bhashiniClient.GetPipelineConfig(s.defaultPipelineID, sourceLang, targetLang)
+		s.router.Record(ctx, pipelineID, sourceLang, targetLang, time.Since(configStart), err)
+		return start, fmt.Errorf("failed to get pipeline config: %w", err)
+	}
+	usage.pipelineID = pipelineID
+
+	for start < len(sentences) {
+		// Fill the chunk up to the input and character limits
+		end, chars := start, 0
+		for end < len(sentences) && end-start < maxInputsPerCompute {
+			chars += utf8.RuneCountInString(sentences[end])
+			if end > start && chars > maxCharsPerCompute {
+				break
+			}
+			end++
 		}
+		chunk := sentences[start:end]
+
+		// Perform translation
+		upstreamStart := time.Now()
+		outputs, err := s.computeChunk(ctx, config, chunk, sourceLang, targetLang)
+		elapsed := time.Since(upstreamStart)
+		usage.UpstreamCalls++
+		usage.UpstreamCharacters += countCharacters(chunk)
+		usage.UpstreamLatencyMS += elapsed.Milliseconds()
+		s.router.Record(ctx, pipelineID, sourceLang, targetLang, elapsed, err)
 		if err != nil {
-			return "", fmt.Errorf("failed to get pipeline config: %w", err)
+			return start, err
 		}
+
+		for j, sourceText := range chunk {
+			translatedText := outputs[j].Target
+
+			// Cache the translation
+			if s.cacheRepo != nil {
+				if err := s.cacheRepo.CacheTranslation(ctx, sourceText, sourceLang, targetLang, translatedText, s.cacheTTL); err != nil {
+					// Log error but don't fail the request
+					metrics.CacheErrors.WithLabelValues(metrics.CacheTierPostgres, "store").Inc()
+					logging.FromContext(ctx).Warn("Cache storage failed", "error", err)
+				}
+			}
+
+			place(sourceText, translatedText)
+		}
+		start = end
 	}
 
-	// Perform translation
-	response, err := s.bhashiniClient.Translate(config, sourceText, sourceLang, targetLang)
+	return start, nil
+}
+
+// computeChunk translates one chunk of sentences, returning an output for
+// each of them
+func (s *TranslationService) computeChunk(ctx context.Context, config *models.PipelineConfigResponse, chunk []string, sourceLang, targetLang string) ([]models.OutputItem, error) {
+	response, err := s.bhashiniClient.TranslateTexts(ctx, config, chunk, sourceLang, targetLang)
 	if err != nil {
-		return "", fmt.Errorf("failed to translate: %w", err)
+		return nil, fmt.Errorf("failed to translate: %w", err)
 	}
 
-	// Extract translation from pipeline response
-	if len(response.PipelineResponse) == 0 {
-		return "", fmt.Errorf("no pipeline response received")
-	}
-	
 	// Find translation task output
-	var translatedText string
+	var outputs []models.OutputItem
 	for _, pipelineItem := range response.PipelineResponse {
-		if pipelineItem.TaskType == "translation" && len(pipelineItem.Output) > 0 {
-			translatedText = pipelineItem.Output[0].Target
+		if pipelineItem.TaskType == "translation" {
+			outputs = pipelineItem.Output
 			break
 		}
 	}
-	
-	if translatedText == "" {
-		return "", fmt.Errorf("no translation output received")
+	if len(outputs) != len(chunk) {
+		return nil, fmt.Errorf("expected %d translation outputs, received %d", len(chunk), len(outputs))
+	}
+	for _, output := range outputs {
+		if output.Target == "" {
+			return nil, fmt.Errorf("no translation output received")
+		}
 	}
+	return outputs, nil
+}
+
+// revalidationKey identifies a cache entry being refreshed in the background
+type revalidationKey struct {
+	sourceText, sourceLang, targetLang string
+}
+
+// revalidating holds the cache entries being refreshed, so concurrent
+// requests serving the same stale entry refresh it once
+var revalidating sync.Map
 
-	// Cache the translation
-	if err := s.cacheRepo.CacheTranslation(sourceText, sourceLang, targetLang, translatedText, s.cacheTTL); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Cache storage error: %v\n", err)
+// revalidate refreshes stale cache entries in the background. The refresh
+// outlives the request, uses its own Bhashini client and is metered against
+// the caller for the upstream work only.
+func (s *TranslationService) revalidate(ctx context.Context, sentences []string, sourceLang, targetLang string) {
+	var claimed []string
+	for _, sentence := range sentences {
+		key := revalidationKey{sourceText: sentence, sourceLang: sourceLang, targetLang: targetLang}
+		if _, busy := revalidating.LoadOrStore(key, struct{}{}); !busy {
+			claimed = append(claimed, sentence)
+		}
+	}
+	if len(claimed) == 0 {
+		return
 	}
 
-	return translatedText, nil
+	ctx = context.WithoutCancel(ctx)
+	apiKeyID := s.caller.APIKeyID
+	go func() {
+		defer func() {
+			for _, sentence := range claimed {
+				revalidating.Delete(revalidationKey{sourceText: sentence, sourceLang: sourceLang, targetLang: targetLang})
+			}
+		}()
+
+		ctx, span := tracing.Start(ctx, "TranslationService.revalidate", trace.WithAttributes(tracing.Pair(sourceLang, targetLang)...))
+		span.SetAttributes(tracing.AttrSentences.Int(len(claimed)))
+		refresher := NewTranslationService(NewBhashiniClient(), s.cacheRepo)
+		var usage sentenceUsage
+		err := refresher.translateUpstream(ctx, claimed, sourceLang, targetLang, &usage, func(string, string) {})
+		recordUsage(apiKeyID, sourceLang, targetLang, usage.pipelineID, usage.UsageCounts)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Stale cache revalidation failed", "sentences", len(claimed), "error", err)
+		}
+		tracing.End(span, err)
+	}()
 }
 
 // GenerateCacheKey generates a unique cache key for translation
@@ -114,7 +507,11 @@ func (s *TranslationService) GenerateCacheKey(sourceText, sourceLang, targetLang
 	return hex.EncodeToString(hash[:])
 }
 
-// CleanExpiredCache removes expired cache entries
-func (s *TranslationService) CleanExpiredCache() error {
-	return s.cacheRepo.CleanExpiredTranslations()
+// CleanExpiredCache removes cache entries expired for longer than either stale
+// window, keeping those that may still be served
+func (s *TranslationService) CleanExpiredCache(ctx context.Context) error {
+	if s.cacheRepo == nil {
+		return nil
+	}
+	return s.cacheRepo.CleanExpiredTranslations(ctx, max(s.staleWhileRevalidate, s.staleIfError))
 }
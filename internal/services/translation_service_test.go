@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/repository"
+)
+
+// fakeCache answers translation cache queries from a map of source text to
+// translation and expiry, recording what is written back
+type fakeCache struct {
+	mu      sync.Mutex
+	entries map[string]fakeCacheEntry
+	written []string
+}
+
+type fakeCacheEntry struct {
+	translation string
+	expiresAt   time.Time
+}
+
+func (c *fakeCache) handle(query string, args []driver.Value) fakeResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "SELECT translated_text, expires_at FROM translation_cache"):
+		entry, ok := c.entries[args[0].(string)]
+		if !ok {
+			return fakeResult{columns: []string{"translated_text", "expires_at"}}
+		}
+		return fakeResult{
+			columns: []string{"translated_text", "expires_at"},
+			rows:    [][]driver.Value{{entry.translation, entry.expiresAt}},
+		}
+	case strings.HasPrefix(query, "INSERT INTO translation_cache"):
+		c.written = append(c.written, args[1].(string))
+	}
+	return fakeResult{}
+}
+
+func (c *fakeCache) writes() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.written...)
+}
+
+// cachedService returns a translation service of the fake Bhashini whose
+// cache holds "Hello", expired for the given time
+func cachedService(t *testing.T, fake *fakeBhashini, expiredFor time.Duration) (*TranslationService, *fakeCache) {
+	t.Helper()
+	t.Setenv("TRANSLATION_CACHE_STALE_WHILE_REVALIDATE", "1h")
+	t.Setenv("TRANSLATION_CACHE_STALE_IF_ERROR", "24h")
+	// Revalidation builds its own client from the environment
+	t.Setenv("BHASHINI_BASE_URL", fake.URL)
+	t.Setenv("BHASHINI_USER_ID", "user")
+	t.Setenv("BHASHINI_API_KEY", strings.Repeat("k", 32))
+
+	cache := &fakeCache{entries: map[string]fakeCacheEntry{
+		"Hello": {translation: "cached", expiresAt: time.Now().Add(-expiredFor)},
+	}}
+	db := openFakeDB(t, cache.handle)
+	return NewTranslationService(fake.client(), repository.NewTranslationRepository(db)), cache
+}
+
+func TestTranslationServiceChunksByCharacters(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+
+	// 20 texts of 240 characters fit in one call, though not in 5000 bytes
+	texts := make([]string, 20)
+	for i := range texts {
+		texts[i] = strings.Repeat("क", 239) + string(rune('a'+i))
+	}
+	if _, err := fake.service().TranslateTexts(context.Background(), texts, "hi", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if inputs := fake.computeInputs(); len(inputs) != 1 {
+		t.Errorf("compute called %d times, want once", len(inputs))
+	}
+}
+
+func TestTranslationServiceServesFreshEntries(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	service, _ := cachedService(t, fake, -time.Hour)
+
+	got, err := service.Translate(context.Background(), "Hello", "en", "hi")
+	if err != nil || got != "cached" {
+		t.Fatalf("Translate() = %q, %v, want the cached translation", got, err)
+	}
+	if service.StaleSentences() != 0 || len(fake.computeInputs()) != 0 {
+		t.Error("a fresh cache entry was treated as stale")
+	}
+}
+
+func TestTranslationServiceRevalidatesStaleEntries(t *testing.T) {
+	fake := newFakeBhashini(t, upperCase)
+	service, cache := cachedService(t, fake, time.Minute)
+
+	got, err := service.Translate(context.Background(), "Hello", "en", "hi")
+	if err != nil || got != "cached" {
+		t.Fatalf("Translate() = %q, %v, want the stale translation", got, err)
+	}
+	if service.StaleSentences() != 1 {
+		t.Errorf("StaleSentences() = %d, want 1", service.StaleSentences())
+	}
+
+	// The entry is refreshed in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for len(cache.writes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if writes := cache.writes(); len(writes) != 1 || writes[0] != "Hello" {
+		t.Errorf("cache writes = %q, want Hello refreshed", writes)
+	}
+}
+
+func TestTranslationServiceServesStaleEntriesOnError(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiredFor time.Duration
+		status     int
+		want       string
+		wantStale  bool
+		wantErr    bool
+	}{
+		{"upstream succeeds", 2 * time.Hour, 0, "HELLO", false, false},
+		{"upstream fails", 2 * time.Hour, http.StatusBadGateway, "cached", true, false},
+		{"too old to stand in", 48 * time.Hour, http.StatusBadGateway, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeBhashini(t, upperCase)
+			fake.failWith(tt.status)
+			service, _ := cachedService(t, fake, tt.expiredFor)
+
+			got, err := service.Translate(context.Background(), "Hello", "en", "hi")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Translate() = %q, %v, want %q", got, err, tt.want)
+			}
+			if stale := service.StaleSentences() > 0; stale != tt.wantStale {
+				t.Errorf("StaleSentences() = %d, want stale %v", service.StaleSentences(), tt.wantStale)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+)
+
+// usageFlushInterval is how often buffered usage is written to the rollups
+const usageFlushInterval = 10 * time.Second
+
+// activeUsageMeter is the meter translations are recorded to, if one runs
+var activeUsageMeter atomic.Pointer[UsageMeter]
+
+// usageKey identifies one hourly rollup
+type usageKey struct {
+	bucketStart time.Time
+	apiKeyID    string
+	sourceLang  string
+	targetLang  string
+	pipelineID  string
+}
+
+// UsageMeter records every translation into hourly rollups per API key,
+// language pair and pipeline. Counters are summed in memory and flushed to
+// Postgres every few seconds, so metering costs no extra query per request.
+type UsageMeter struct {
+	usageRepo *repository.UsageRepository
+	mu        sync.Mutex
+	pending   map[usageKey]*models.UsageCounts
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewUsageMeter creates a new usage meter
+func NewUsageMeter(db *sql.DB) *UsageMeter {
+	return &UsageMeter{
+		usageRepo: repository.NewUsageRepository(db),
+		pending:   make(map[usageKey]*models.UsageCounts),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start makes the meter record the process's translations and starts
+// flushing them
+func (m *UsageMeter) Start() {
+	activeUsageMeter.Store(m)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(usageFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.flush()
+			}
+		}
+	}()
+}
+
+// Stop stops recording and writes out what is still buffered
+func (m *UsageMeter) Stop() {
+	activeUsageMeter.CompareAndSwap(m, nil)
+	close(m.stop)
+	m.wg.Wait()
+	m.flush()
+}
+
+// record adds one translation's counters to its rollup
+func (m *UsageMeter) record(key usageKey, counts models.UsageCounts) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if pending := m.pending[key]; pending != nil {
+		pending.Add(counts)
+		return
+	}
+	m.pending[key] = &counts
+}
+
+// flush writes the buffered counters. If the write fails they are kept for
+// the next flush.
+func (m *UsageMeter) flush() {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[usageKey]*models.UsageCounts)
+	m.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	rollups := make([]models.UsageRollup, 0, len(pending))
+	for key, counts := range pending {
+		rollups = append(rollups, models.UsageRollup{
+			BucketStart: key.bucketStart,
+			APIKeyID:    key.apiKeyID,
+			SourceLang:  key.sourceLang,
+			TargetLang:  key.targetLang,
+			PipelineID:  key.pipelineID,
+			UsageCounts: *counts,
+		})
+	}
+
+	if err := m.usageRepo.AddRollups(context.Background(), rollups); err != nil {
+		slog.Error("Usage flush failed", "rollups", len(rollups), "error", err)
+		for key, counts := range pending {
+			m.record(key, *counts)
+		}
+	}
+}
+
+// recordUsage records a translation with the running meter, if any
+func recordUsage(apiKeyID, sourceLang, targetLang, pipelineID string, counts models.UsageCounts) {
+	meter := activeUsageMeter.Load()
+	if meter == nil {
+		return
+	}
+	meter.record(usageKey{
+		bucketStart: time.Now().UTC().Truncate(time.Hour),
+		apiKeyID:    apiKeyID,
+		sourceLang:  sourceLang,
+		targetLang:  targetLang,
+		pipelineID:  pipelineID,
+	}, counts)
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookBatchSize is how many due deliveries a dispatcher claims per poll
+const webhookBatchSize = 20
+
+// WebhookDispatcher posts job events to callback URLs. Deliveries are queued
+// in Postgres when a job finishes and sent in the background, with
+// exponential backoff between failed attempts and every attempt recorded in
+// the delivery log.
+type WebhookDispatcher struct {
+	webhookRepo  *repository.WebhookRepository
+	secret       string
+	httpClient   *http.Client
+	maxAttempts  int
+	pollInterval time.Duration
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewWebhookDispatcher creates a webhook dispatcher configured from the environment
+func NewWebhookDispatcher(db *sql.DB) *WebhookDispatcher {
+	// Parse max attempts from env (default 8)
+	maxAttempts := 8
+	if attemptsStr := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); attemptsStr != "" {
+		if parsed, err := strconv.Atoi(attemptsStr); err == nil && parsed > 0 {
+			maxAttempts = parsed
+		}
+	}
+
+	return &WebhookDispatcher{
+		webhookRepo:  repository.NewWebhookRepository(db),
+		secret:       DefaultWebhookSecret(),
+		httpClient:   newWebhookHTTPClient(durationFromEnv("WEBHOOK_TIMEOUT", 10*time.Second)),
+		maxAttempts:  maxAttempts,
+		pollInterval: durationFromEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		stop:         make(chan struct{}),
+	}
+}
+
+// DefaultWebhookSecret returns the signing secret used for jobs submitted
+// without their own callback secret (WEBHOOK_SECRET)
+func DefaultWebhookSecret() string {
+	return strings.TrimSpace(os.Getenv("WEBHOOK_SECRET"))
+}
+
+// SignWebhookPayload returns the signature header value for a payload sent at
+// timestamp: "v1=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers recompute it with the same secret and should reject old timestamps.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// jobWebhook builds the delivery of a finished job's completed or failed
+// event to its callback URL, or returns nil if it has none
+func jobWebhook(job *models.TranslationJob) (*models.WebhookDelivery, error) {
+	if job.CallbackURL == "" {
+		return nil, nil
+	}
+
+	eventType := models.WebhookEventJobCompleted
+	if job.Status == models.JobStatusFailed {
+		eventType = models.WebhookEventJobFailed
+	}
+
+	event := models.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      *job,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	return &models.WebhookDelivery{
+		ID:        event.ID,
+		JobID:     job.ID,
+		EventType: eventType,
+		URL:       job.CallbackURL,
+		Payload:   string(payload),
+	}, nil
+}
+
+// Start launches the background delivery loop
+func (d *WebhookDispatcher) Start() {
+	d.wg.Add(1)
+	go d.run()
+}
+
+// Stop asks the delivery loop to stop and waits for in-flight deliveries
+func (d *WebhookDispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// run sends due deliveries until the dispatcher is stopped
+func (d *WebhookDispatcher) run() {
+	defer d.wg.Done()
+
+	// A claimed delivery is hidden from other dispatchers for longer than one send can take
+	lease := 2*d.httpClient.Timeout + time.Minute
+
+	for {
+		deliveries, err := d.webhookRepo.ClaimDueDeliveries(context.Background(), webhookBatchSize, lease)
+		if err != nil {
+			slog.Error("Webhook claim failed", "error", err)
+		}
+		for _, delivery := range deliveries {
+			d.deliver(delivery)
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			select {
+			case <-d.stop:
+				return
+			case <-time.After(d.pollInterval):
+			}
+		} else {
+			select {
+			case <-d.stop:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// deliver makes one attempt at a claimed delivery and records its outcome
+func (d *WebhookDispatcher) deliver(delivery models.WebhookDelivery) {
+	secret := delivery.Secret
+	if secret == "" {
+		secret = d.secret
+	}
+
+	start := time.Now()
+	statusCode, err := d.post(delivery, secret)
+	attempt := models.WebhookDeliveryAttempt{
+		Attempt:    delivery.Attempts,
+		StatusCode: statusCode,
+		DurationMs: int(time.Since(start).Milliseconds()),
+	}
+
+	status := models.WebhookStatusDelivered
+	nextAttempt := time.Now()
+	if err != nil {
+		attempt.Error = err.Error()
+		switch {
+		case statusCode == http.StatusGone || delivery.Attempts >= d.maxAttempts:
+			// The receiver is gone for good, or we have run out of attempts
+			status = models.WebhookStatusFailed
+		default:
+			// Back off 30s, 1m, 2m... up to an hour before the next attempt
+			status = models.WebhookStatusPending
+			delay := time.Hour
+			if delivery.Attempts < 8 {
+				delay = 30 * time.Second << (delivery.Attempts - 1)
+			}
+			nextAttempt = nextAttempt.Add(delay)
+		}
+	}
+
+	if err := d.webhookRepo.RecordAttempt(context.Background(), delivery.ID, attempt, status, nextAttempt); err != nil {
+		slog.Error("Webhook attempt recording failed", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// post sends a signed delivery, returning the response status code and an
+// error for anything other than a 2xx response
+func (d *WebhookDispatcher) post(delivery models.WebhookDelivery, secret string) (int, error) {
+	if secret == "" {
+		return 0, fmt.Errorf("no signing secret: set callback_secret on the job or WEBHOOK_SECRET")
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	httpReq, err := http.NewRequest("POST", delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(WebhookIDHeader, delivery.ID)
+	httpReq.Header.Set(WebhookEventHeader, delivery.EventType)
+	httpReq.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	httpReq.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, timestamp, body))
+
+	resp, err := d.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("receiver returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
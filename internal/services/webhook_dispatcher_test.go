@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"user-service/internal/models"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	got := SignWebhookPayload("secret", 1700000000, []byte(`{"id":"evt"}`))
+	if want := "v1=7c757099788fba43a4fe1e0c3b767303fdd971ab6183bc900d3de418c62b08b0"; got != want {
+		t.Errorf("SignWebhookPayload() = %q, want %q", got, want)
+	}
+}
+
+func TestInternalAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"::1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"100.128.0.1", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := internalAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("internalAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+		blocked bool
+	}{
+		{"https://8.8.8.8/hook", false, false},
+		{"http://[2001:4860:4860::8888]:8080/hook", false, false},
+		{"http://127.0.0.1/hook", true, true},
+		{"http://169.254.169.254/latest/meta-data", true, true},
+		{"http://[::1]/hook", true, true},
+		{"ftp://8.8.8.8/hook", true, false},
+		{"/relative/hook", true, false},
+	}
+	for _, tt := range tests {
+		err := CheckWebhookURL(context.Background(), tt.url)
+		if (err != nil) != tt.wantErr || errors.Is(err, ErrWebhookTargetBlocked) != tt.blocked {
+			t.Errorf("CheckWebhookURL(%q) = %v, want error %v, blocked %v", tt.url, err, tt.wantErr, tt.blocked)
+		}
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	if err := CheckWebhookURL(context.Background(), "http://127.0.0.1/hook"); err != nil {
+		t.Errorf("CheckWebhookURL() = %v with private networks allowed", err)
+	}
+}
+
+func TestWebhookDispatcherPost(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+
+	delivery := models.WebhookDelivery{ID: "evt", EventType: models.WebhookEventJobCompleted, URL: receiver.URL, Payload: `{"id":"evt"}`}
+
+	// The receiver listens on loopback, which the dial check refuses
+	dispatcher := &WebhookDispatcher{httpClient: newWebhookHTTPClient(time.Second)}
+	if _, err := dispatcher.post(delivery, "secret"); !errors.Is(err, ErrWebhookTargetBlocked) {
+		t.Fatalf("post() = %v, want ErrWebhookTargetBlocked", err)
+	}
+	if received != nil {
+		t.Fatal("post() reached a loopback receiver")
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	dispatcher = &WebhookDispatcher{httpClient: newWebhookHTTPClient(time.Second)}
+	if status, err := dispatcher.post(delivery, "secret"); err != nil || status != http.StatusOK {
+		t.Fatalf("post() = %d, %v, want 200", status, err)
+	}
+	timestamp, err := strconv.ParseInt(received.Header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header: %v", err)
+	}
+	if got, want := received.Header.Get(WebhookSignatureHeader), SignWebhookPayload("secret", timestamp, body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if received.Header.Get(WebhookIDHeader) != "evt" || received.Header.Get(WebhookEventHeader) != models.WebhookEventJobCompleted {
+		t.Errorf("headers = %v", received.Header)
+	}
+
+	if _, err := dispatcher.post(delivery, ""); err == nil {
+		t.Error("post() sent a delivery without a secret")
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookTargetBlocked is returned for callback URLs that resolve to
+// loopback, private, link-local or other internal addresses
+var ErrWebhookTargetBlocked = errors.New("callback_url must not point to a loopback, private or link-local address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not
+// count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// webhookAllowPrivateNetworks reports whether webhooks may be sent to
+// internal addresses (WEBHOOK_ALLOW_PRIVATE_NETWORKS, default false), for
+// receivers on the same network in development
+func webhookAllowPrivateNetworks() bool {
+	allow, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS"))
+	return allow
+}
+
+// internalAddress reports whether an address is one webhooks must not reach
+func internalAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || sharedAddressSpace.Contains(addr)
+}
+
+// CheckWebhookURL checks that a callback URL is an absolute http(s) URL whose
+// host resolves only to public addresses, with errors worded for the
+// callback_url field. The addresses are checked again when a delivery
+// connects, in case the host's DNS records change.
+func CheckWebhookURL(ctx context.Context, callbackURL string) error {
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return errors.New("callback_url must be an absolute http or https URL")
+	}
+	if webhookAllowPrivateNetworks() {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("callback_url host could not be resolved: %w", err)
+	}
+	for _, addr := range addrs {
+		if internalAddress(addr) {
+			return ErrWebhookTargetBlocked
+		}
+	}
+	return nil
+}
+
+// newWebhookHTTPClient creates the client deliveries are sent with. It never
+// follows redirects, and unless private networks are allowed it refuses to
+// connect to internal addresses, whatever the callback host resolves to at
+// the time.
+func newWebhookHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !webhookAllowPrivateNetworks() {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || internalAddress(addrPort.Addr()) {
+				return ErrWebhookTargetBlocked
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // connect to receivers directly, so the dial check sees their addresses
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
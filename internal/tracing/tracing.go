@@ -0,0 +1,142 @@
+// Package tracing sets up OpenTelemetry tracing and defines the span
+// attributes shared by the server, the translation service and the Bhashini
+// client
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultServiceName names the service in exported spans without OTEL_SERVICE_NAME
+const defaultServiceName = "translation-service"
+
+// Span attributes
+const (
+	AttrSourceLang  = attribute.Key("translation.source_lang")
+	AttrTargetLang  = attribute.Key("translation.target_lang")
+	AttrTexts       = attribute.Key("translation.texts")
+	AttrSentences   = attribute.Key("translation.sentences")
+	AttrCacheResult = attribute.Key("translation.cache_result")
+	AttrCacheHits   = attribute.Key("translation.cache_hits")
+	AttrPipelineID  = attribute.Key("bhashini.pipeline_id")
+	AttrServiceID   = attribute.Key("bhashini.service_id")
+	AttrEndpoint    = attribute.Key("bhashini.endpoint")
+)
+
+// Values of AttrCacheResult
+const (
+	CacheHit      = "hit"      // every sentence came from the cache
+	CacheMiss     = "miss"     // no sentence came from the cache
+	CachePartial  = "partial"  // some sentences came from the cache
+	CacheStale    = "stale"    // a lookup found an expired entry
+	CacheDisabled = "disabled" // translated without a cache
+)
+
+// tracer creates every span of the service. It follows the provider
+// installed by Setup, and creates no-op spans until then.
+var tracer = otel.Tracer("user-service")
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, opts...)
+}
+
+// End ends a span, marking it failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Pair returns the attributes of a language pair
+func Pair(sourceLang, targetLang string) []attribute.KeyValue {
+	return []attribute.KeyValue{AttrSourceLang.String(sourceLang), AttrTargetLang.String(targetLang)}
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The exporter is chosen by OTEL_TRACES_EXPORTER:
+//
+//	none     no spans are exported (default); trace context is still propagated
+//	otlp     OTLP over HTTP, or gRPC with OTEL_EXPORTER_OTLP_PROTOCOL=grpc, to OTEL_EXPORTER_OTLP_ENDPOINT
+//	console  JSON spans on stdout
+//	file     JSON spans appended to OTEL_TRACES_FILE (default traces.json)
+//
+// The returned function flushes buffered spans and must be called on exit.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closeFile func() error
+	var err error
+	switch name := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_EXPORTER"))); name {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		switch protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol {
+		case "", "http/protobuf":
+			exporter, err = otlptracehttp.New(ctx)
+		case "grpc":
+			exporter, err = otlptracegrpc.New(ctx)
+		default:
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL %q is not supported, use http/protobuf or grpc", protocol)
+		}
+	case "console", "stdout":
+		exporter, err = stdouttrace.New()
+	case "file":
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = "traces.json"
+		}
+		file, openErr := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if openErr != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", openErr)
+		}
+		closeFile = file.Close
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER %q is not supported, use none, otlp, console or file", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	// The sampler follows OTEL_TRACES_SAMPLER, parent-based always-on by default
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			if closeErr := closeFile(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
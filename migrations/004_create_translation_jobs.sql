@@ -0,0 +1,36 @@
+-- Create translation_jobs table for asynchronous batch and document translation
+CREATE TABLE IF NOT EXISTS translation_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_type VARCHAR(20) NOT NULL,              -- batch or document
+    document_format VARCHAR(20),                -- markdown, subtitles or text for document jobs
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued, running, completed, failed, cancelled
+    total_items INTEGER NOT NULL DEFAULT 0,
+    completed_items INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    locked_by VARCHAR(100),
+    locked_at TIMESTAMP,
+    run_after TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+-- Create translation_job_items table; each item is translated and saved on its own
+-- so an interrupted job resumes where it stopped
+CREATE TABLE IF NOT EXISTS translation_job_items (
+    job_id UUID NOT NULL REFERENCES translation_jobs(id) ON DELETE CASCADE,
+    item_index INTEGER NOT NULL,
+    source_text TEXT NOT NULL,
+    source_lang VARCHAR(10) NOT NULL,
+    target_lang VARCHAR(10) NOT NULL,
+    translated_text TEXT,
+    completed_at TIMESTAMP,
+    PRIMARY KEY (job_id, item_index)
+);
+
+-- Create indexes for claiming work and cleaning up old results
+CREATE INDEX IF NOT EXISTS idx_translation_jobs_claim ON translation_jobs(status, run_after, created_at);
+CREATE INDEX IF NOT EXISTS idx_translation_jobs_expires ON translation_jobs(expires_at);
+CREATE INDEX IF NOT EXISTS idx_translation_job_items_pending ON translation_job_items(job_id, item_index) WHERE completed_at IS NULL;
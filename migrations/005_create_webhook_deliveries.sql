@@ -0,0 +1,32 @@
+-- Add callback settings to translation_jobs
+ALTER TABLE translation_jobs ADD COLUMN IF NOT EXISTS callback_url TEXT;
+ALTER TABLE translation_jobs ADD COLUMN IF NOT EXISTS callback_secret TEXT;
+
+-- Create webhook_deliveries table; one row per event to deliver
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL REFERENCES translation_jobs(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    url TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, delivered, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP
+);
+
+-- Create webhook_delivery_attempts table as the delivery log
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER,
+    error TEXT,
+    duration_ms INTEGER NOT NULL,
+    attempted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (delivery_id, attempt)
+);
+
+-- Create indexes for the dispatcher and the delivery log
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_job ON webhook_deliveries(job_id);
@@ -0,0 +1,21 @@
+-- Create api_keys table; only a SHA-256 hash of each key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(20) NOT NULL,             -- first characters of the key, to tell keys apart
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,                      -- translate, manage
+    callback_url TEXT,                           -- default webhook for jobs created with this key
+    callback_secret TEXT,
+    rotated_from UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- Record which key created each job, so clients only see their own jobs
+ALTER TABLE translation_jobs ADD COLUMN IF NOT EXISTS api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_translation_jobs_api_key ON translation_jobs(api_key_id);
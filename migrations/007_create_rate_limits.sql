@@ -0,0 +1,22 @@
+-- Per-key limits; NULL falls back to the RATE_LIMIT_RPS, CHAR_QUOTA and
+-- CHAR_QUOTA_PERIOD defaults, 0 means unlimited
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS rate_limit INTEGER;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS char_quota BIGINT;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS char_quota_period VARCHAR(10) CHECK (char_quota_period IN ('day', 'month'));
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS quota_exempt_cache_hits BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Request counter for the current one-second window, one row per key
+CREATE TABLE IF NOT EXISTS api_key_request_windows (
+    api_key_id UUID PRIMARY KEY REFERENCES api_keys(id) ON DELETE CASCADE,
+    window_start TIMESTAMP NOT NULL,
+    requests INTEGER NOT NULL
+);
+
+-- Characters charged to each key per quota period
+CREATE TABLE IF NOT EXISTS api_key_character_usage (
+    api_key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    period VARCHAR(10) NOT NULL,                 -- day, month
+    period_start TIMESTAMP NOT NULL,
+    characters BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, period, period_start)
+);
@@ -0,0 +1,21 @@
+-- Hourly usage rollups per API key, language pair and pipeline
+CREATE TABLE IF NOT EXISTS usage_rollups (
+    bucket_start TIMESTAMP NOT NULL,              -- start of the UTC hour
+    api_key_id UUID NOT NULL,                     -- nil UUID for the admin key and unattributed work
+    source_lang VARCHAR(10) NOT NULL,
+    target_lang VARCHAR(10) NOT NULL,
+    pipeline_id VARCHAR(64) NOT NULL DEFAULT '',  -- empty when nothing went upstream
+    requests BIGINT NOT NULL DEFAULT 0,           -- translation calls
+    errors BIGINT NOT NULL DEFAULT 0,
+    sentences BIGINT NOT NULL DEFAULT 0,
+    cache_hits BIGINT NOT NULL DEFAULT 0,         -- sentences served from the cache
+    characters BIGINT NOT NULL DEFAULT 0,         -- source characters translated
+    upstream_characters BIGINT NOT NULL DEFAULT 0,
+    upstream_calls BIGINT NOT NULL DEFAULT 0,
+    latency_ms BIGINT NOT NULL DEFAULT 0,         -- summed over requests
+    upstream_latency_ms BIGINT NOT NULL DEFAULT 0,  -- summed over upstream calls
+    PRIMARY KEY (bucket_start, api_key_id, source_lang, target_lang, pipeline_id)
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_usage_rollups_api_key ON usage_rollups(api_key_id, bucket_start);
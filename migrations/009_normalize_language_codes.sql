@@ -0,0 +1,136 @@
+-- Rewrite language codes stored before languages were normalised to canonical
+-- tags ("hi", "gom", "mni-Beng"), so cached translations, usage and queued
+-- jobs recorded under other forms such as "hi-IN" or "kok" stay reachable.
+-- Safe to run more than once.
+BEGIN;
+
+-- canonical_language mirrors constants.NormalizeLanguage: the code is
+-- lowercased and aliases mapped to the code Bhashini uses, a region is
+-- dropped, and the script is kept only when it is not the default. Tags the
+-- registry does not know are returned unchanged, as the service does.
+CREATE OR REPLACE FUNCTION pg_temp.canonical_language(tag TEXT)
+RETURNS TEXT AS $$
+DECLARE
+    subtags TEXT[] := array_remove(regexp_split_to_array(tag, '[-_]'), '');
+    lang TEXT := lower(subtags[1]);
+    rest TEXT[] := subtags[2:];
+    script TEXT;
+    scripts TEXT[];
+BEGIN
+    IF COALESCE(array_length(subtags, 1), 0) NOT BETWEEN 1 AND 3 THEN
+        RETURN tag;
+    END IF;
+
+    lang := COALESCE((
+        SELECT aliases.code
+        FROM (VALUES
+            ('eng', 'en'), ('hin', 'hi'), ('mar', 'mr'), ('tam', 'ta'), ('tel', 'te'),
+            ('guj', 'gu'), ('pan', 'pa'), ('ori', 'or'), ('ory', 'or'), ('mal', 'ml'),
+            ('ben', 'bn'), ('asm', 'as'), ('kan', 'kn'), ('urd', 'ur'), ('nep', 'ne'),
+            ('npi', 'ne'), ('san', 'sa'), ('kok', 'gom'), ('kas', 'ks'), ('snd', 'sd')
+        ) AS aliases(name, code)
+        WHERE aliases.name = lang
+    ), lang);
+
+    -- The scripts of each language, the default first
+    SELECT languages.scripts INTO scripts
+    FROM (VALUES
+        ('en', ARRAY['Latn']), ('hi', ARRAY['Deva']), ('mr', ARRAY['Deva']), ('ta', ARRAY['Taml']),
+        ('te', ARRAY['Telu']), ('gu', ARRAY['Gujr']), ('pa', ARRAY['Guru']), ('or', ARRAY['Orya']),
+        ('ml', ARRAY['Mlym']), ('bn', ARRAY['Beng']), ('as', ARRAY['Beng']), ('kn', ARRAY['Knda']),
+        ('ur', ARRAY['Arab']), ('ne', ARRAY['Deva']), ('sa', ARRAY['Deva']), ('sat', ARRAY['Olck']),
+        ('mni', ARRAY['Mtei', 'Beng']), ('gom', ARRAY['Deva']), ('mai', ARRAY['Deva']),
+        ('doi', ARRAY['Deva']), ('brx', ARRAY['Deva']), ('ks', ARRAY['Arab', 'Deva']),
+        ('sd', ARRAY['Arab', 'Deva'])
+    ) AS languages(code, scripts)
+    WHERE languages.code = lang;
+    IF scripts IS NULL THEN
+        RETURN tag;
+    END IF;
+
+    script := scripts[1];
+    IF octet_length(rest[1]) = 4 THEN
+        script := upper(left(rest[1], 1)) || lower(substr(rest[1], 2));
+        IF NOT script = ANY (scripts) THEN
+            RETURN tag;
+        END IF;
+        rest := rest[2:];
+    END IF;
+    IF COALESCE(array_length(rest, 1), 0) > 1
+        OR (array_length(rest, 1) = 1 AND rest[1] !~ '^([A-Za-z]{2}|[0-9]{3})$') THEN
+        RETURN tag;
+    END IF;
+
+    IF script = scripts[1] THEN
+        RETURN lang;
+    END IF;
+    RETURN lang || '-' || script;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Job items only need rewriting in place
+UPDATE translation_job_items
+SET source_lang = pg_temp.canonical_language(source_lang),
+    target_lang = pg_temp.canonical_language(target_lang)
+WHERE source_lang <> pg_temp.canonical_language(source_lang)
+   OR target_lang <> pg_temp.canonical_language(target_lang);
+
+-- Cache entries whose canonical pair is already cached keep the entry that
+-- expires last
+CREATE TEMP TABLE legacy_translation_cache ON COMMIT DROP AS
+SELECT id, source_text,
+    pg_temp.canonical_language(source_lang) AS source_lang,
+    pg_temp.canonical_language(target_lang) AS target_lang,
+    translated_text, created_at, expires_at
+FROM translation_cache
+WHERE source_lang <> pg_temp.canonical_language(source_lang)
+   OR target_lang <> pg_temp.canonical_language(target_lang);
+
+DELETE FROM translation_cache WHERE id IN (SELECT id FROM legacy_translation_cache);
+
+INSERT INTO translation_cache (id, source_text, source_lang, target_lang, translated_text, created_at, expires_at)
+SELECT DISTINCT ON (source_text, source_lang, target_lang)
+    id, source_text, source_lang, target_lang, translated_text, created_at, expires_at
+FROM legacy_translation_cache
+ORDER BY source_text, source_lang, target_lang, expires_at DESC
+ON CONFLICT (source_text, source_lang, target_lang) DO UPDATE SET
+    translated_text = EXCLUDED.translated_text,
+    created_at = EXCLUDED.created_at,
+    expires_at = EXCLUDED.expires_at
+WHERE translation_cache.expires_at < EXCLUDED.expires_at;
+
+-- Usage rollups recorded under other forms are merged into the canonical pair
+CREATE TEMP TABLE legacy_usage_rollups ON COMMIT DROP AS
+SELECT bucket_start, api_key_id,
+    pg_temp.canonical_language(source_lang) AS source_lang,
+    pg_temp.canonical_language(target_lang) AS target_lang,
+    pipeline_id, requests, errors, sentences, cache_hits, characters,
+    upstream_characters, upstream_calls, latency_ms, upstream_latency_ms
+FROM usage_rollups
+WHERE source_lang <> pg_temp.canonical_language(source_lang)
+   OR target_lang <> pg_temp.canonical_language(target_lang);
+
+DELETE FROM usage_rollups
+WHERE source_lang <> pg_temp.canonical_language(source_lang)
+   OR target_lang <> pg_temp.canonical_language(target_lang);
+
+INSERT INTO usage_rollups AS u (bucket_start, api_key_id, source_lang, target_lang, pipeline_id,
+    requests, errors, sentences, cache_hits, characters,
+    upstream_characters, upstream_calls, latency_ms, upstream_latency_ms)
+SELECT bucket_start, api_key_id, source_lang, target_lang, pipeline_id,
+    SUM(requests), SUM(errors), SUM(sentences), SUM(cache_hits), SUM(characters),
+    SUM(upstream_characters), SUM(upstream_calls), SUM(latency_ms), SUM(upstream_latency_ms)
+FROM legacy_usage_rollups
+GROUP BY bucket_start, api_key_id, source_lang, target_lang, pipeline_id
+ON CONFLICT (bucket_start, api_key_id, source_lang, target_lang, pipeline_id) DO UPDATE SET
+    requests = u.requests + EXCLUDED.requests,
+    errors = u.errors + EXCLUDED.errors,
+    sentences = u.sentences + EXCLUDED.sentences,
+    cache_hits = u.cache_hits + EXCLUDED.cache_hits,
+    characters = u.characters + EXCLUDED.characters,
+    upstream_characters = u.upstream_characters + EXCLUDED.upstream_characters,
+    upstream_calls = u.upstream_calls + EXCLUDED.upstream_calls,
+    latency_ms = u.latency_ms + EXCLUDED.latency_ms,
+    upstream_latency_ms = u.upstream_latency_ms + EXCLUDED.upstream_latency_ms;
+
+COMMIT;